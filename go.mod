@@ -6,6 +6,7 @@ require (
 	github.com/docbull/inlab-fabric-udp-proto v0.0.0-20210530052143-f04241ac7a7a
 	github.com/lucas-clemente/quic-go v0.27.0
 	google.golang.org/grpc v1.46.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -5,23 +5,30 @@ import (
 	"mp2bs/multipath"
 	"net"
 	"context"
+	"flag"
+	"net/http"
 
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc"
 
 	udp "github.com/docbull/inlab-fabric-udp-proto"
-	// protoG "github.com/golang/protobuf/proto"
 )
 
-const MSG_SIZE = 4096 // 4KB
 const FILE_SIZE = 10485760 // 10MB
 
+var configPath = flag.String("config", "", "configuration file of multipath session (.yaml, .yml or .json)")
+var metricsAddr = flag.String("metrics", "", "address of Prometheus metrics endpoint (e.g. :9100), disabled if empty")
+var insecure = flag.Bool("insecure", false, "do not verify the certificate of mpserver (e.g. self-signed), for testing only")
+
 type Message struct {
 	Block	*udp.Envelope
+	Server	udp.UDPServiceClient // UDPService of MPServer over multipath session
 }
 
 func main() {
 
+	flag.Parse()
+
 	msg := &Message{
 		Block:			nil,
 	}
@@ -31,9 +38,64 @@ func main() {
 		SecretEnvelope:	nil,
 	}
 
+	conn := msg.ConnectMPServer()
+	defer conn.Close()
+
 	msg.grpcListen()
 }
 
+// gRPC client of MPServer whose transport is multipath session
+func (msg *Message) ConnectMPServer() *grpc.ClientConn {
+
+	serverAddr := "127.0.0.1:4242"
+
+	// Load configuration (environment variables override it)
+	config := multipath.DefaultConfig()
+	config.ListenAddrs = []string{"127.0.0.1:4251", "127.0.0.1:4252"}
+	if *configPath != "" {
+		var err error
+		config, err = multipath.LoadConfig(*configPath)
+		if err != nil {
+			panic(err)
+		}
+	} else if err := config.LoadEnv(); err != nil {
+		panic(err)
+	}
+	if *insecure {
+		config.TLSInsecureSkipVerify = true
+	}
+
+	// Create Session Manager
+	sessionManager, err := multipath.CreateSessionManager(config)
+	if err != nil {
+		panic(err)
+	}
+
+	// Prometheus metrics of multipath sessions
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", sessionManager.MetricsHandler())
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fmt.Printf("Metrics server: %v\n", err)
+			}
+		}()
+	}
+
+	// Session is connected when the first RPC is called
+	conn, err := grpc.Dial(serverAddr,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(sessionManager.DialContext),
+		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(FILE_SIZE), grpc.MaxCallRecvMsgSize(FILE_SIZE)),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	msg.Server = udp.NewUDPServiceClient(conn)
+
+	return conn
+}
 
 func (msg *Message) grpcListen() {
 
@@ -65,60 +127,19 @@ func (msg *Message) BlockDataForUDP(ctx context.Context, envelope *udp.Envelope)
 	msg.Block.Signature = envelope.Signature
 	msg.Block.SecretEnvelope = envelope.SecretEnvelope
 
-	go msg.SendBlock()
-
-	return &udp.Status{Code: udp.StatusCode_Ok}, nil
+	return msg.SendBlock(ctx, envelope)
 }
 
-func (msg *Message) SendBlock() {
-
-	addrList := []string{"127.0.0.1:4251", "127.0.0.1:4252"}
-	serverAddr := "127.0.0.1:4242"
-
-	// Create Session Manager
-	sessionManager := multipath.CreateSessionManager(addrList)
-
-	// Connect to server
-	session := sessionManager.Connect(serverAddr)
-	fmt.Printf("MPClient: SessionID=%d\n", session.SessionID)
-
-	start, end := 0, 0
-	total := 0
-
-	for start < len(msg.Block.Payload) {
-		if start + MSG_SIZE < len(msg.Block.Payload) {
-			end = start + MSG_SIZE
-		} else {
-			end = len(msg.Block.Payload)
-		}
-
-		/*
-		Block := &udp.Envelope{
-			Payload:		msg.Block.Payload[start:end],
-			Signature:		nil,
-			SecretEnvelope:	nil,
-		}
-
-		// data marshalling 
-		marshalledEnvelope, err := protoG.Marshal(Block)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		*/
-
-		sendBytes, err := session.Write(msg.Block.Payload[start:end])
-		if err != nil {
-			panic(err)
-		}
+// Forward a whole envelope to MPServer over multipath session
+func (msg *Message) SendBlock(ctx context.Context, envelope *udp.Envelope) (*udp.Status, error) {
 
-		total = total + sendBytes
-		fmt.Printf("MPClient: Send %d bytes to server! (total=%d) \n", sendBytes, total)
-
-		start = end
+	res, err := msg.Server.BlockDataForUDP(ctx, envelope)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
 	}
 
-	session.Close()
+	fmt.Printf("MPClient: Send %d bytes to server! (status=%v) \n", len(envelope.Payload), res.Code)
 
-	fmt.Printf("MPClient: Finish! Send %d bytes to server!!!!!!!!!!1 \n", total)
+	return res, nil
 }
@@ -4,19 +4,27 @@ import (
 	"fmt"
 	"mp2bs/multipath"
 	"context"
+	"flag"
+	"net/http"
 
 	udp "github.com/docbull/inlab-fabric-udp-proto"
 	"google.golang.org/grpc"
 )
 
-const MSG_SIZE = 4096 // 4KB
+const FILE_SIZE = 10485760 // 10MB
+
+var configPath = flag.String("config", "", "configuration file of multipath session (.yaml, .yml or .json)")
+var metricsAddr = flag.String("metrics", "", "address of Prometheus metrics endpoint (e.g. :9100), disabled if empty")
 
 type Message struct {
 	Block	*udp.Envelope
+	Peer	udp.UDPServiceClient
 }
 
 func main() {
 
+	flag.Parse()
+
 	msg := &Message{
 		Block:			nil,
 	}
@@ -32,56 +40,81 @@ func main() {
 func (msg *Message) MPServerListen() {
 
 	peerIP := ":16220"
-	conn, err := grpc.Dial(peerIP, grpc.WithInsecure())
+	conn, err := grpc.Dial(peerIP, grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(FILE_SIZE), grpc.MaxCallRecvMsgSize(FILE_SIZE)))
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 	defer conn.Close()
-	server := udp.NewUDPServiceClient(conn)
+	msg.Peer = udp.NewUDPServiceClient(conn)
 
-	addrList := []string{"127.0.0.1:4242", "127.0.0.1:4243"}
+	// Load configuration (environment variables override it)
+	config := multipath.DefaultConfig()
+	config.ListenAddrs = []string{"127.0.0.1:4242", "127.0.0.1:4243"}
+	if *configPath != "" {
+		config, err = multipath.LoadConfig(*configPath)
+		if err != nil {
+			panic(err)
+		}
+	} else if err := config.LoadEnv(); err != nil {
+		panic(err)
+	}
 
 	// Create Session Manager
-	sessionManager := multipath.CreateSessionManager(addrList)
+	sessionManager, err := multipath.CreateSessionManager(config)
+	if err != nil {
+		panic(err)
+	}
+	defer sessionManager.Close()
+
+	// Prometheus metrics of multipath sessions
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", sessionManager.MetricsHandler())
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fmt.Printf("Metrics server: %v\n", err)
+			}
+		}()
+	}
 
-	// Accept
-	session := sessionManager.Accept()
-	fmt.Printf("MPServer: SessionID=%d\n", session.SessionID)
+	// gRPC server whose transport is multipath session
+	lis := multipath.CreateListener(sessionManager)
+	defer lis.Close()
 
-	total := 0
-	i := 0
+	fmt.Println("MPServer: wating MPClient connection...")
 
-	for {
-		buf := make([]byte, MSG_SIZE)
-		recvBytes, err := session.Read(buf)
-		if err != nil {
-			panic(err)
-		}
-		if recvBytes <= 0 {
-			break
-		}
+	grpcServer := grpc.NewServer(
+		grpc.MaxSendMsgSize(FILE_SIZE),
+		grpc.MaxRecvMsgSize(FILE_SIZE),
+	)
+	udp.RegisterUDPServiceServer(grpcServer, msg)
 
-		total = total + recvBytes
-		fmt.Printf("MPServer: Read len [%d] : n=%d, total=%d \n", i, recvBytes, total)
-		i++
+	if err := grpcServer.Serve(lis); err != nil {
+		fmt.Println(err)
+	}
+}
 
-		msg.Block.Payload = buf[:recvBytes]
+// Forward a whole envelope received from MPClient to the Peer
+func (msg *Message) BlockDataForUDP(ctx context.Context, envelope *udp.Envelope) (*udp.Status, error) {
 
-		res, err := server.BlockDataForUDP(context.Background(), msg.Block)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		if res.Code != udp.StatusCode_Ok {
-			fmt.Println("MPServer: Not OK for block transmission:", res.Code)
-			return
-		} else {
-			fmt.Println("MPServer: Received message from Peer:", res)
-		}
-	}
+	fmt.Printf("MPServer: Receive %d bytes from MPClient \n", len(envelope.Payload))
 
-	fmt.Printf("MPServer: Receive %d byte from MPClient! \n", total)
-}
+	msg.Block.Payload = envelope.Payload
+	msg.Block.Signature = envelope.Signature
+	msg.Block.SecretEnvelope = envelope.SecretEnvelope
 
+	res, err := msg.Peer.BlockDataForUDP(ctx, envelope)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	if res.Code != udp.StatusCode_Ok {
+		fmt.Println("MPServer: Not OK for block transmission:", res.Code)
+	} else {
+		fmt.Println("MPServer: Received message from Peer:", res)
+	}
 
+	return res, nil
+}
@@ -0,0 +1,67 @@
+package multipath
+
+import (
+	"bytes"
+)
+
+const ACK_PACKET_HEADER_LEN = 11 // header length of ack packet
+
+type AckPacket struct {
+	Type      byte
+	Length    uint16
+	SessionID uint32
+	SeqNumber uint32
+}
+
+func CreateAckPacket(sessionID uint32, seq uint32) *AckPacket {
+	packet := AckPacket{}
+	packet.Type = ACK_PACKET
+	packet.Length = ACK_PACKET_HEADER_LEN
+	packet.SessionID = sessionID
+	packet.SeqNumber = seq
+	return &packet
+}
+
+func ParseAckPacket(r *bytes.Reader) (*AckPacket, error) {
+
+	packetType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packetLegnth, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	seqNumber, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := &AckPacket{}
+	packet.Type = packetType
+	packet.Length = packetLegnth
+	packet.SessionID = sessionID
+	packet.SeqNumber = seqNumber
+
+	return packet, nil
+}
+
+func (p *AckPacket) GetType() byte {
+	return p.Type
+}
+
+// Writes Ack Packet
+func (p *AckPacket) Write(b *bytes.Buffer) error {
+	b.WriteByte(p.Type)
+	WriteUint16(b, uint16(p.Length))
+	WriteUint32(b, uint32(p.SessionID))
+	WriteUint32(b, uint32(p.SeqNumber))
+	return nil
+}
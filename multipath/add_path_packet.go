@@ -0,0 +1,68 @@
+package multipath
+
+import (
+	"bytes"
+)
+
+const ADD_PATH_PACKET_HEADER_LEN = 7 // header length of add path packet
+
+// Advertise an address to be connected as a new path of the session
+// (sent by the acceptor, since only the initiator can connect a new path)
+type AddPathPacket struct {
+	Type      byte
+	Length    uint16
+	SessionID uint32
+	NicInfo   NicInfo
+}
+
+func CreateAddPathPacket(sessionID uint32, nicInfo NicInfo) *AddPathPacket {
+	packet := AddPathPacket{}
+	packet.Type = ADD_PATH_PACKET
+	packet.Length = uint16(ADD_PATH_PACKET_HEADER_LEN + nicInfo.Len())
+	packet.SessionID = sessionID
+	packet.NicInfo = nicInfo
+	return &packet
+}
+
+func ParseAddPathPacket(r *bytes.Reader) (*AddPathPacket, error) {
+	packetType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packetLegnth, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	nicInfo, err := ParseNicInfo(r)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := &AddPathPacket{}
+	packet.Type = packetType
+	packet.Length = packetLegnth
+	packet.SessionID = sessionID
+	packet.NicInfo = nicInfo
+
+	return packet, nil
+}
+
+func (p *AddPathPacket) GetType() byte {
+	return p.Type
+}
+
+// Writes Add Path Packet
+func (p *AddPathPacket) Write(b *bytes.Buffer) error {
+	b.WriteByte(p.Type)
+	WriteUint16(b, uint16(p.Length))
+	WriteUint32(b, uint32(p.SessionID))
+	p.NicInfo.Write(b)
+	return nil
+}
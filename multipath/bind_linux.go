@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package multipath
+
+import (
+	"syscall"
+)
+
+// Bind a socket to the network interface (SO_BINDTODEVICE, requires CAP_NET_RAW)
+func bindToDevice(device string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		controlErr := c.Control(func(fd uintptr) {
+			err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, device)
+		})
+		if controlErr != nil {
+			return controlErr
+		}
+		return err
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package multipath
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// SO_BINDTODEVICE is only supported on Linux (bind to the address of the interface instead)
+func bindToDevice(device string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("binding to device (%s) is not supported on this platform", device)
+	}
+}
@@ -1,16 +1,431 @@
 package multipath
 
-// TODO: define config type, read from configuration file
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
-var CONFIG_USER_WRR_WEIGHT = [2]uint32{5, 2}
+	"gopkg.in/yaml.v2"
+)
 
-var verbose_mode = true //TODO
+var DEFAULT_USER_WRR_WEIGHT = []uint32{5, 2}
 
 const PACKET_SIZE = 1500
 
+const DEFAULT_MAX_MESSAGE_SIZE = 16 * 1024 * 1024 // 16MB
+
+const (
+	HELLO_PACKET       = 1
+	HELLO_ACK_PACKET   = 2
+	DATA_PACKET        = 3
+	GOODBYE_PACKET     = 4
+	ACK_PACKET         = 5
+	ADD_PATH_PACKET    = 6
+	REMOVE_PATH_PACKET = 7
+	PING_PACKET        = 8
+	PONG_PACKET        = 9
+	GOODBYE_ACK_PACKET = 10
+)
+
+// Environment variables overriding configuration
 const (
-	HELLO_PACKET     = 1
-	HELLO_ACK_PACKET = 2
-	DATA_PACKET      = 3
-	GOODBYE_PACKET   = 4
+	ENV_LISTEN_ADDRS    = "MP2BS_LISTEN_ADDRS"    // comma separated addresses
+	ENV_SCHEDULER       = "MP2BS_SCHEDULER"       // user_wrr, net_wrr, lowest_rtt, round_robin or redundant
+	ENV_USER_WRR_WEIGHT = "MP2BS_USER_WRR_WEIGHT" // comma separated weights
+	ENV_PACKET_SIZE     = "MP2BS_PACKET_SIZE"
+	ENV_PAYLOAD_SIZE    = "MP2BS_PAYLOAD_SIZE"
+	ENV_MAX_MSG_SIZE    = "MP2BS_MAX_MESSAGE_SIZE"
+	ENV_VERBOSE         = "MP2BS_VERBOSE"
+	ENV_AUTO_CONNECT    = "MP2BS_AUTO_CONNECT"
+	ENV_DISCOVER_NICS   = "MP2BS_DISCOVER_NICS"
+	ENV_DISCOVER_PORT   = "MP2BS_DISCOVER_PORT"
+	ENV_LOCAL_ADDRS     = "MP2BS_LOCAL_ADDRS"  // comma separated addresses
+	ENV_BIND_DEVICES    = "MP2BS_BIND_DEVICES" // comma separated interface names
+	ENV_PING_INTERVAL   = "MP2BS_PING_INTERVAL_MS"
+	ENV_PING_MAX_LOST   = "MP2BS_PING_MAX_LOST"
+	ENV_RECONNECT       = "MP2BS_RECONNECT_INTERVAL_MS"
+	ENV_RECONNECT_MAX   = "MP2BS_RECONNECT_MAX_ATTEMPTS"
+	ENV_RESUME_TIMEOUT  = "MP2BS_RESUME_TIMEOUT_MS"
+	ENV_IDLE_TIMEOUT    = "MP2BS_IDLE_TIMEOUT_MS"
+	ENV_TLS_CERT_FILE   = "MP2BS_TLS_CERT_FILE"
+	ENV_TLS_KEY_FILE    = "MP2BS_TLS_KEY_FILE"
+	ENV_TLS_CA_FILE     = "MP2BS_TLS_CA_FILE"
+	ENV_TLS_SERVER_NAME = "MP2BS_TLS_SERVER_NAME"
+	ENV_TLS_CLIENT_AUTH = "MP2BS_TLS_REQUIRE_CLIENT_CERT"
+	ENV_TLS_INSECURE    = "MP2BS_TLS_INSECURE_SKIP_VERIFY"
 )
+
+var schedulerNames = map[string]int{
+	"user_wrr":    SCHED_USER_WRR,
+	"net_wrr":     SCHED_NET_WRR,
+	"lowest_rtt":  SCHED_LOWEST_RTT,
+	"round_robin": SCHED_ROUND_ROBIN,
+	"redundant":   SCHED_REDUNDANT,
+}
+
+// Configuration of SessionManager and its sessions
+type Config struct {
+	ListenAddrs   []string `json:"listen_addrs" yaml:"listen_addrs"`
+	Scheduler     string   `json:"scheduler" yaml:"scheduler"`               // default scheduler of sessions
+	UserWrrWeight []uint32 `json:"user_wrr_weight" yaml:"user_wrr_weight"`   // weights for user_wrr scheduler
+	PacketSize    int      `json:"packet_size" yaml:"packet_size"`           // maximum packet size to receive
+	PayloadSize   int      `json:"payload_size" yaml:"payload_size"`         // payload size of data packet
+	MaxMsgSize    int      `json:"max_message_size" yaml:"max_message_size"` // maximum message size of ReadMessage()
+	Verbose       bool     `json:"verbose" yaml:"verbose"`
+	AutoConnect   bool     `json:"auto_connect" yaml:"auto_connect"`   // connect to all addresses advertised by the peer
+	DiscoverNics  bool     `json:"discover_nics" yaml:"discover_nics"` // also listen on addresses of all network interfaces
+	DiscoverPort  int      `json:"discover_port" yaml:"discover_port"` // port of discovered addresses (0 for any port)
+
+	// Local addresses of outbound paths (each new path is bound to the next one)
+	// If empty, IPs of ListenAddrs are used
+	LocalAddrs  []string `json:"local_addrs" yaml:"local_addrs"`
+	BindDevices []string `json:"bind_devices" yaml:"bind_devices"` // interface of each local address (SO_BINDTODEVICE, Linux only)
+
+	// Path health monitoring
+	// A path is down if nothing is received during PingIntervalMs * PingMaxLost
+	PingIntervalMs       int `json:"ping_interval_ms" yaml:"ping_interval_ms"`             // interval of keepalive probes (0 to disable)
+	PingMaxLost          int `json:"ping_max_lost" yaml:"ping_max_lost"`                   // unanswered probes to mark a path down
+	ReconnectIntervalMs  int `json:"reconnect_interval_ms" yaml:"reconnect_interval_ms"`   // first interval of reconnecting a lost path (0 to disable)
+	ReconnectMaxAttempts int `json:"reconnect_max_attempts" yaml:"reconnect_max_attempts"` // attempts to reconnect a lost path (0 for no limit)
+
+	// Write() waits for a reconnected path up to ResumeTimeoutMs if all paths are lost (0 to fail immediately)
+	ResumeTimeoutMs int `json:"resume_timeout_ms" yaml:"resume_timeout_ms"`
+
+	// Session is closed if nothing is received through any path during IdleTimeoutMs (0 to disable)
+	// (keepalive probes keep a session with healthy paths from being idle)
+	IdleTimeoutMs int `json:"idle_timeout_ms" yaml:"idle_timeout_ms"`
+
+	// TLS of all paths (PEM files)
+	// Without certificate, the acceptor uses a self-signed certificate
+	// Without CA, the initiator verifies the acceptor against system roots unless TLSInsecureSkipVerify is set
+	TLSCertFile           string `json:"tls_cert_file" yaml:"tls_cert_file"`                       // certificate (chain) of this peer
+	TLSKeyFile            string `json:"tls_key_file" yaml:"tls_key_file"`                         // private key of TLSCertFile
+	TLSCAFile             string `json:"tls_ca_file" yaml:"tls_ca_file"`                           // CA certificates verifying the peer
+	TLSServerName         string `json:"tls_server_name" yaml:"tls_server_name"`                   // name in the certificate of the acceptor (default: host of the connected address)
+	TLSRequireClientCert  bool   `json:"tls_require_client_cert" yaml:"tls_require_client_cert"`   // acceptor rejects initiators without a verified certificate
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify" yaml:"tls_insecure_skip_verify"` // initiator does not verify the acceptor (e.g. self-signed certificate), for testing only
+
+	// TLS configuration overriding the TLS fields above (not serialized)
+	TLSConfig *tls.Config `json:"-" yaml:"-"`
+}
+
+func DefaultConfig() *Config {
+	c := Config{
+		ListenAddrs:   make([]string, 0),
+		Scheduler:     "user_wrr",
+		UserWrrWeight: append([]uint32(nil), DEFAULT_USER_WRR_WEIGHT...),
+		PacketSize:    PACKET_SIZE,
+		PayloadSize:   DATA_PACKET_PAYLOAD_SIZE,
+		MaxMsgSize:    DEFAULT_MAX_MESSAGE_SIZE,
+		Verbose:       true,
+		AutoConnect:   true,
+		DiscoverNics:  false,
+		DiscoverPort:  0,
+		LocalAddrs:    make([]string, 0),
+		BindDevices:   make([]string, 0),
+
+		PingIntervalMs:       1000,
+		PingMaxLost:          3,
+		ReconnectIntervalMs:  3000,
+		ReconnectMaxAttempts: 10,
+		ResumeTimeoutMs:      10000,
+		IdleTimeoutMs:        60000,
+	}
+
+	return &c
+}
+
+// Load configuration from YAML (.yaml, .yml) or JSON (.json) file
+// Unspecified fields keep default values, and environment variables override the file
+// Unknown fields are errors in both formats (e.g. misspelled keys)
+func LoadConfig(path string) (*Config, error) {
+	c := DefaultConfig()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	default:
+		err = fmt.Errorf("unsupported configuration file format (%s)", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.LoadEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, c.Validate()
+}
+
+// Override configuration by environment variables
+func (c *Config) LoadEnv() error {
+	var err error
+
+	if value, exists := os.LookupEnv(ENV_LISTEN_ADDRS); exists {
+		c.ListenAddrs = splitList(value)
+	}
+
+	if value, exists := os.LookupEnv(ENV_SCHEDULER); exists {
+		c.Scheduler = value
+	}
+
+	if value, exists := os.LookupEnv(ENV_USER_WRR_WEIGHT); exists {
+		weights := splitList(value)
+		c.UserWrrWeight = make([]uint32, len(weights))
+		for i, weight := range weights {
+			w, err := strconv.ParseUint(weight, 10, 32)
+			if err != nil {
+				return fmt.Errorf("%s: %v", ENV_USER_WRR_WEIGHT, err)
+			}
+			c.UserWrrWeight[i] = uint32(w)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_PACKET_SIZE); exists {
+		c.PacketSize, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_PACKET_SIZE, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_PAYLOAD_SIZE); exists {
+		c.PayloadSize, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_PAYLOAD_SIZE, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_MAX_MSG_SIZE); exists {
+		c.MaxMsgSize, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_MAX_MSG_SIZE, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_VERBOSE); exists {
+		c.Verbose, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_VERBOSE, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_AUTO_CONNECT); exists {
+		c.AutoConnect, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_AUTO_CONNECT, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_DISCOVER_NICS); exists {
+		c.DiscoverNics, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_DISCOVER_NICS, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_DISCOVER_PORT); exists {
+		c.DiscoverPort, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_DISCOVER_PORT, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_LOCAL_ADDRS); exists {
+		c.LocalAddrs = splitList(value)
+	}
+
+	if value, exists := os.LookupEnv(ENV_BIND_DEVICES); exists {
+		c.BindDevices = strings.Split(value, ",") // empty item means no device for the local address
+	}
+
+	if value, exists := os.LookupEnv(ENV_PING_INTERVAL); exists {
+		c.PingIntervalMs, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_PING_INTERVAL, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_PING_MAX_LOST); exists {
+		c.PingMaxLost, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_PING_MAX_LOST, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_RECONNECT); exists {
+		c.ReconnectIntervalMs, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_RECONNECT, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_RECONNECT_MAX); exists {
+		c.ReconnectMaxAttempts, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_RECONNECT_MAX, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_RESUME_TIMEOUT); exists {
+		c.ResumeTimeoutMs, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_RESUME_TIMEOUT, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_IDLE_TIMEOUT); exists {
+		c.IdleTimeoutMs, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_IDLE_TIMEOUT, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_TLS_CERT_FILE); exists {
+		c.TLSCertFile = value
+	}
+
+	if value, exists := os.LookupEnv(ENV_TLS_KEY_FILE); exists {
+		c.TLSKeyFile = value
+	}
+
+	if value, exists := os.LookupEnv(ENV_TLS_CA_FILE); exists {
+		c.TLSCAFile = value
+	}
+
+	if value, exists := os.LookupEnv(ENV_TLS_SERVER_NAME); exists {
+		c.TLSServerName = value
+	}
+
+	if value, exists := os.LookupEnv(ENV_TLS_CLIENT_AUTH); exists {
+		c.TLSRequireClientCert, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_TLS_CLIENT_AUTH, err)
+		}
+	}
+
+	if value, exists := os.LookupEnv(ENV_TLS_INSECURE); exists {
+		c.TLSInsecureSkipVerify, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ENV_TLS_INSECURE, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) Validate() error {
+	if _, exists := schedulerNames[c.Scheduler]; !exists {
+		return fmt.Errorf("unknown scheduler (%s)", c.Scheduler)
+	}
+
+	for _, weight := range c.UserWrrWeight {
+		if weight == 0 {
+			return fmt.Errorf("weight of user_wrr scheduler should be greater than 0")
+		}
+	}
+
+	// Packet length field is 16 bits
+	if c.PacketSize <= DATA_PACKET_HEADER_LEN || c.PacketSize > 0xFFFF {
+		return fmt.Errorf("invalid packet size (%d)", c.PacketSize)
+	}
+
+	if c.PayloadSize <= 0 || c.PayloadSize+DATA_PACKET_HEADER_LEN > c.PacketSize {
+		return fmt.Errorf("payload size (%d) should be in range of 1 to packet size - %d", c.PayloadSize, DATA_PACKET_HEADER_LEN)
+	}
+
+	if c.DiscoverPort < 0 || c.DiscoverPort > 0xFFFF {
+		return fmt.Errorf("invalid discover port (%d)", c.DiscoverPort)
+	}
+
+	for _, addr := range c.LocalAddrs {
+		if net.ParseIP(addr) != nil {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid local address (%s)", addr)
+		}
+	}
+
+	if len(c.BindDevices) > len(c.LocalAddrs) {
+		return fmt.Errorf("each bind device needs a local address (0.0.0.0 for any address)")
+	}
+
+	if c.PingIntervalMs < 0 || c.ReconnectIntervalMs < 0 {
+		return fmt.Errorf("ping interval and reconnect interval should not be negative")
+	}
+
+	if c.ReconnectMaxAttempts < 0 {
+		return fmt.Errorf("reconnect attempts should not be negative")
+	}
+
+	if c.ResumeTimeoutMs < 0 || c.IdleTimeoutMs < 0 {
+		return fmt.Errorf("resume timeout and idle timeout should not be negative")
+	}
+
+	if c.PingIntervalMs > 0 && c.PingMaxLost <= 0 {
+		return fmt.Errorf("ping max lost (%d) should be greater than 0", c.PingMaxLost)
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS certificate and key should be configured together")
+	}
+
+	if c.TLSRequireClientCert && c.TLSCAFile == "" && c.TLSConfig == nil {
+		return fmt.Errorf("TLS CA is needed to verify client certificates")
+	}
+
+	if c.TLSInsecureSkipVerify && (c.TLSCAFile != "" || c.TLSServerName != "") {
+		return fmt.Errorf("TLS CA or server name cannot be verified with tls_insecure_skip_verify")
+	}
+
+	// Message length field is 32 bits
+	if c.MaxMsgSize <= 0 || uint64(c.MaxMsgSize) > 0xFFFFFFFF {
+		return fmt.Errorf("invalid max message size (%d)", c.MaxMsgSize)
+	}
+
+	return nil
+}
+
+// Create a scheduler of the configured type
+func (c *Config) CreateScheduler() SessionScheduler {
+	schedType := schedulerNames[c.Scheduler]
+
+	if schedType == SCHED_USER_WRR || schedType == SCHED_NET_WRR {
+		return CreateWrrScheduler(schedType, c.UserWrrWeight, c.PayloadSize)
+	}
+
+	return CreateSessionScheduler(schedType)
+}
+
+func (c *Config) logger() logger {
+	return logger{verbose: c.Verbose}
+}
+
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
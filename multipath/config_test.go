@@ -0,0 +1,69 @@
+package multipath
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Configurations do not share the default weights
+func TestDefaultConfigWeightsAreCopied(t *testing.T) {
+	config := DefaultConfig()
+	config.UserWrrWeight[0] = 99
+
+	if DEFAULT_USER_WRR_WEIGHT[0] == 99 || DefaultConfig().UserWrrWeight[0] == 99 {
+		t.Fatal("default weights are changed through a configuration")
+	}
+}
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", "listen_addrs: [127.0.0.1:4242]\nuser_wrr_weight: [3, 1]\n"},
+		{"config.json", `{"listen_addrs": ["127.0.0.1:4242"], "user_wrr_weight": [3, 1]}`},
+	}
+
+	for _, test := range tests {
+		config, err := LoadConfig(writeConfigFile(t, test.name, test.content))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(config.UserWrrWeight, []uint32{3, 1}) || len(config.ListenAddrs) != 1 {
+			t.Fatalf("%s: %+v", test.name, config)
+		}
+	}
+
+	if !reflect.DeepEqual(DEFAULT_USER_WRR_WEIGHT, []uint32{5, 2}) {
+		t.Fatalf("default weights are changed to %v", DEFAULT_USER_WRR_WEIGHT)
+	}
+}
+
+// Misspelled keys are errors in both formats
+func TestLoadConfigUnknownField(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", "listen_adrs: [127.0.0.1:4242]\n"},
+		{"config.json", `{"listen_adrs": ["127.0.0.1:4242"]}`},
+	}
+
+	for _, test := range tests {
+		_, err := LoadConfig(writeConfigFile(t, test.name, test.content))
+		if err == nil {
+			t.Errorf("%s: unknown field is accepted", test.name)
+		}
+	}
+}
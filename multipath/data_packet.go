@@ -2,6 +2,8 @@ package multipath
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 )
 
 const DATA_PACKET_HEADER_LEN = 12 // header length of data packet (except for payload size)
@@ -55,6 +57,10 @@ func ParseDataPacket(r *bytes.Reader) (*DataPacket, error) {
 		return nil, err
 	}
 
+	if packetLegnth < DATA_PACKET_HEADER_LEN {
+		return nil, fmt.Errorf("packet length (%d) is shorter than header", packetLegnth)
+	}
+
 	packet := &DataPacket{}
 	packet.Type = packetType
 	packet.Length = packetLegnth
@@ -62,11 +68,18 @@ func ParseDataPacket(r *bytes.Reader) (*DataPacket, error) {
 	packet.PathID = pathID
 	packet.SeqNumber = seqNumber
 	packet.Payload = make([]byte, packetLegnth-DATA_PACKET_HEADER_LEN)
-	r.Read(packet.Payload)
+	_, err = io.ReadFull(r, packet.Payload)
+	if err != nil {
+		return nil, err
+	}
 
 	return packet, nil
 }
 
+func (p *DataPacket) GetType() byte {
+	return p.Type
+}
+
 // Writes Data Packet
 func (p *DataPacket) Write(b *bytes.Buffer) error {
 	b.WriteByte(p.Type)
@@ -0,0 +1,41 @@
+package multipath
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownPacketType = errors.New("multipath: unknown packet type")
+	ErrInvalidPacket     = errors.New("multipath: invalid packet")
+	ErrSessionNotFound   = errors.New("multipath: session not found")
+	ErrPathAuthFailed    = errors.New("multipath: path authentication failed")
+	ErrPeerMismatch      = errors.New("multipath: peer certificate differs from the first path")
+	ErrHandshakeFailed   = errors.New("multipath: handshake failed")
+	ErrIncompatiblePeer  = errors.New("multipath: incompatible peer")
+	ErrNoAvailablePath   = errors.New("multipath: no available path")
+	ErrPathClosed        = errors.New("multipath: path is closed")
+	ErrPathDown          = errors.New("multipath: path is down (keepalive timeout)")
+	ErrLastPath          = errors.New("multipath: last path cannot be removed")
+	ErrInvalidAddress    = errors.New("multipath: invalid address")
+	ErrMessageTooLarge   = errors.New("multipath: message is too large")
+)
+
+// Application error code to close QUIC connection of a path
+const QUIC_ERROR_HANDSHAKE_FAILED = 1
+
+// Error on a path of a session
+type PathError struct {
+	Op     string // operation (e.g. "connect", "receive")
+	PathID int    // -1 if the path is not added to the session yet
+	Addr   string // remote address of the path
+	Err    error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("multipath: %s %s (PathID=%d): %v", e.Op, e.Addr, e.PathID, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,58 @@
+package multipath
+
+import (
+	"bytes"
+)
+
+const GOODBYE_ACK_PACKET_HEADER_LEN = 7 // header length of goodbye ack packet
+
+// Acknowledge Goodbye after all data packets of the peer are received
+type GoodbyeAckPacket struct {
+	Type      byte
+	Length    uint16
+	SessionID uint32
+}
+
+func CreateGoodbyeAckPacket(sessionID uint32) *GoodbyeAckPacket {
+	packet := GoodbyeAckPacket{}
+	packet.Type = GOODBYE_ACK_PACKET
+	packet.Length = GOODBYE_ACK_PACKET_HEADER_LEN
+	packet.SessionID = sessionID
+	return &packet
+}
+
+func ParseGoodbyeAckPacket(r *bytes.Reader) (*GoodbyeAckPacket, error) {
+	packetType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packetLegnth, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := &GoodbyeAckPacket{}
+	packet.Type = packetType
+	packet.Length = packetLegnth
+	packet.SessionID = sessionID
+
+	return packet, nil
+}
+
+func (p *GoodbyeAckPacket) GetType() byte {
+	return p.Type
+}
+
+// Writes Goodbye Ack Packet
+func (p *GoodbyeAckPacket) Write(b *bytes.Buffer) error {
+	b.WriteByte(p.Type)
+	WriteUint16(b, uint16(p.Length))
+	WriteUint32(b, uint32(p.SessionID))
+	return nil
+}
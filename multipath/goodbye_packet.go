@@ -4,19 +4,23 @@ import (
 	"bytes"
 )
 
-const GOODBYE_PACKET_HEADER_LEN = 7 // header length of goodbye packet
+const GOODBYE_PACKET_HEADER_LEN = 11 // header length of goodbye packet
 
+// FinalSeqNumber is the sequence number following the last data packet
+// (the peer acknowledges Goodbye after all data packets before it are received)
 type GoodbyePacket struct {
-	Type      byte
-	Length    uint16
-	SessionID uint32
+	Type           byte
+	Length         uint16
+	SessionID      uint32
+	FinalSeqNumber uint32
 }
 
-func CreateGoodbyePacket(sessionID uint32) *GoodbyePacket {
+func CreateGoodbyePacket(sessionID uint32, finalSeq uint32) *GoodbyePacket {
 	packet := GoodbyePacket{}
 	packet.Type = GOODBYE_PACKET
 	packet.Length = GOODBYE_PACKET_HEADER_LEN
 	packet.SessionID = sessionID
+	packet.FinalSeqNumber = finalSeq
 	return &packet
 }
 
@@ -37,18 +41,29 @@ func ParseGoodbyePacket(r *bytes.Reader) (*GoodbyePacket, error) {
 		return nil, err
 	}
 
+	finalSeq, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
 	packet := &GoodbyePacket{}
 	packet.Type = packetType
 	packet.Length = packetLegnth
 	packet.SessionID = sessionID
+	packet.FinalSeqNumber = finalSeq
 
 	return packet, nil
 }
 
+func (p *GoodbyePacket) GetType() byte {
+	return p.Type
+}
+
 // Writes Goodbye Packet
 func (p *GoodbyePacket) Write(b *bytes.Buffer) error {
 	b.WriteByte(p.Type)
 	WriteUint16(b, uint16(p.Length))
 	WriteUint32(b, uint32(p.SessionID))
+	WriteUint32(b, p.FinalSeqNumber)
 	return nil
 }
@@ -2,39 +2,170 @@ package multipath
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
 )
 
-const HELLO_ACK_PACKET_HEADER_LEN = 8 // header length of hello ack packet
+const HELLO_ACK_PACKET_HEADER_LEN = 35 // header length of hello ack packet
+const NIC_INFO_HEADER_LEN = 6          // length of NicInfo except for address
+const RESUMPTION_TOKEN_LEN = 16        // length of token for additional paths and resumption
+const MAX_NIC_INFOS = 255              // NumPath is a byte
 
+// Address family of NicInfo
+const (
+	NIC_FAMILY_IPV4 = 4
+	NIC_FAMILY_IPV6 = 6
+)
+
+// Address of a network interface advertised to the peer
+// Addresses with the same NicID belong to the same interface (e.g. IPv4 and IPv6 addresses)
 type NicInfo struct {
-	Type    byte
-	AddrLen byte
-	Addr    []byte
+	Type   byte   // NIC_TYPE_*
+	NicID  uint16 // identifier of the interface
+	Family byte   // NIC_FAMILY_IPV4 or NIC_FAMILY_IPV6
+	Port   uint16
+	Addr   []byte // 4 bytes for IPv4, 16 bytes for IPv6
+}
+
+// Create NicInfo from host:port (host should be an IP address)
+func CreateNicInfo(nicType byte, nicID uint16, addr string) (NicInfo, error) {
+	nicInfo := NicInfo{Type: nicType, NicID: nicID}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nicInfo, err
+	}
+
+	ip := udpAddr.IP
+	if ip == nil {
+		// Unspecified host (e.g. ":4242")
+		ip = net.IPv4zero
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		nicInfo.Family = NIC_FAMILY_IPV4
+		nicInfo.Addr = ip4
+	} else {
+		nicInfo.Family = NIC_FAMILY_IPV6
+		nicInfo.Addr = ip.To16()
+	}
+	nicInfo.Port = uint16(udpAddr.Port)
+
+	return nicInfo, nil
+}
+
+func ParseNicInfo(r *bytes.Reader) (NicInfo, error) {
+	nicInfo := NicInfo{}
+
+	var err error
+	nicInfo.Type, err = r.ReadByte()
+	if err != nil {
+		return nicInfo, err
+	}
+
+	nicInfo.NicID, err = ReadUint16(r)
+	if err != nil {
+		return nicInfo, err
+	}
+
+	nicInfo.Family, err = r.ReadByte()
+	if err != nil {
+		return nicInfo, err
+	}
+
+	nicInfo.Port, err = ReadUint16(r)
+	if err != nil {
+		return nicInfo, err
+	}
+
+	switch nicInfo.Family {
+	case NIC_FAMILY_IPV4:
+		nicInfo.Addr = make([]byte, net.IPv4len)
+	case NIC_FAMILY_IPV6:
+		nicInfo.Addr = make([]byte, net.IPv6len)
+	default:
+		return nicInfo, fmt.Errorf("unknown address family (%d)", nicInfo.Family)
+	}
+
+	_, err = io.ReadFull(r, nicInfo.Addr)
+	if err != nil {
+		return nicInfo, err
+	}
+
+	return nicInfo, nil
+}
+
+// Writes NicInfo
+func (n *NicInfo) Write(b *bytes.Buffer) error {
+	b.WriteByte(n.Type)
+	WriteUint16(b, n.NicID)
+	b.WriteByte(n.Family)
+	WriteUint16(b, n.Port)
+	b.Write(n.Addr)
+	return nil
+}
+
+// Encoded length of NicInfo
+func (n *NicInfo) Len() int {
+	return NIC_INFO_HEADER_LEN + len(n.Addr)
 }
 
+// host:port to connect
+func (n *NicInfo) String() string {
+	return net.JoinHostPort(net.IP(n.Addr).String(), strconv.Itoa(int(n.Port)))
+}
+
+// Version and Capabilities are negotiated by the acceptor (see negotiateProtocol())
+// Token is issued to the initiator, which presents it in Hello of the following paths
+// AckSeqNumber is the next sequence number to be delivered (see RecvBuffer)
 type HelloAckPacket struct {
-	Type      byte
-	Length    uint16
-	SessionID uint32
-	NumPath   byte
-	NicInfos  []NicInfo
+	Type           byte
+	Length         uint16
+	Version        byte
+	SessionID      uint32
+	Capabilities   uint32
+	MaxPayloadSize uint16 // maximum payload size of data packets which the acceptor can receive
+	Token          [RESUMPTION_TOKEN_LEN]byte
+	AckSeqNumber   uint32
+	NumPath        byte
+	NicInfos       []NicInfo
 }
 
-func CreateHelloAckPacket(sessionID uint32, nicInfos []NicInfo) *HelloAckPacket {
+// nicInfos should fit in the packet (see fitNicInfos())
+func CreateHelloAckPacket(version byte, sessionID uint32, capabilities uint32, maxPayloadSize uint16, token [RESUMPTION_TOKEN_LEN]byte, ackSeq uint32, nicInfos []NicInfo) *HelloAckPacket {
 	packet := HelloAckPacket{}
 	packet.Type = HELLO_ACK_PACKET
+	packet.Version = version
 	packet.SessionID = sessionID
+	packet.Capabilities = capabilities
+	packet.MaxPayloadSize = maxPayloadSize
+	packet.Token = token
+	packet.AckSeqNumber = ackSeq
 	packet.NumPath = byte(len(nicInfos))
 	packet.NicInfos = nicInfos
 	nicInfoLen := 0
 	for _, nicInfo := range nicInfos {
-		nicInfoLen += int(nicInfo.AddrLen + 2)
+		nicInfoLen += nicInfo.Len()
 	}
 	packet.Length = uint16(HELLO_ACK_PACKET_HEADER_LEN + nicInfoLen)
 
 	return &packet
 }
 
+// Split nicInfos into the ones which fit in a Hello ACK of maxLen bytes and the dropped ones
+func fitNicInfos(nicInfos []NicInfo, maxLen int) ([]NicInfo, []NicInfo) {
+	length := HELLO_ACK_PACKET_HEADER_LEN
+	for i, nicInfo := range nicInfos {
+		length += nicInfo.Len()
+		if i >= MAX_NIC_INFOS || length > maxLen {
+			return nicInfos[:i], nicInfos[i:]
+		}
+	}
+	return nicInfos, nil
+}
+
 func ParseHelloAckPacket(r *bytes.Reader) (*HelloAckPacket, error) {
 	packetType, err := r.ReadByte()
 	if err != nil {
@@ -46,11 +177,37 @@ func ParseHelloAckPacket(r *bytes.Reader) (*HelloAckPacket, error) {
 		return nil, err
 	}
 
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
 	sessionID, err := ReadUint32(r)
 	if err != nil {
 		return nil, err
 	}
 
+	capabilities, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	maxPayloadSize, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := &HelloAckPacket{}
+	_, err = io.ReadFull(r, packet.Token[:])
+	if err != nil {
+		return nil, err
+	}
+
+	ackSeq, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
 	numPath, err := r.ReadByte()
 	if err != nil {
 		return nil, err
@@ -58,47 +215,43 @@ func ParseHelloAckPacket(r *bytes.Reader) (*HelloAckPacket, error) {
 
 	nicInfos := make([]NicInfo, numPath)
 	for i := 0; i < len(nicInfos); i++ {
-		nicInfos[i].Type, err = r.ReadByte()
+		nicInfos[i], err = ParseNicInfo(r)
 		if err != nil {
 			return nil, err
 		}
-		nicInfos[i].AddrLen, err = r.ReadByte()
-		if err != nil {
-			return nil, err
-		}
-
-		nicInfos[i].Addr = make([]byte, nicInfos[i].AddrLen)
-		for j := 0; j < int(nicInfos[i].AddrLen); j++ {
-			nicInfos[i].Addr[j], err = r.ReadByte()
-			if err != nil {
-				return nil, err
-			}
-		}
 	}
 
-	packet := &HelloAckPacket{}
 	packet.Type = packetType
 	packet.Length = packetLegnth
+	packet.Version = version
 	packet.SessionID = sessionID
+	packet.Capabilities = capabilities
+	packet.MaxPayloadSize = maxPayloadSize
+	packet.AckSeqNumber = ackSeq
 	packet.NumPath = numPath
 	packet.NicInfos = nicInfos
 
 	return packet, nil
 }
 
+func (p *HelloAckPacket) GetType() byte {
+	return p.Type
+}
+
 // Writes Hello Ack packet
 func (p *HelloAckPacket) Write(b *bytes.Buffer) error {
 	b.WriteByte(p.Type)
 	WriteUint16(b, uint16(p.Length))
+	b.WriteByte(p.Version)
 	WriteUint32(b, uint32(p.SessionID))
+	WriteUint32(b, p.Capabilities)
+	WriteUint16(b, p.MaxPayloadSize)
+	b.Write(p.Token[:])
+	WriteUint32(b, p.AckSeqNumber)
 	b.WriteByte(p.NumPath)
 
 	for i := 0; i < int(p.NumPath); i++ {
-		b.WriteByte(p.NicInfos[i].Type)
-		b.WriteByte(p.NicInfos[i].AddrLen)
-		for j := 0; j < int(p.NicInfos[i].AddrLen); j++ {
-			b.WriteByte(p.NicInfos[i].Addr[j])
-		}
+		p.NicInfos[i].Write(b)
 	}
 
 	return nil
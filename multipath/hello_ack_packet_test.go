@@ -0,0 +1,102 @@
+package multipath
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func createNicInfos(t *testing.T, num int, host string) []NicInfo {
+	nicInfos := make([]NicInfo, 0, num)
+	for i := 0; i < num; i++ {
+		nicInfo, err := CreateNicInfo(NIC_TYPE_UNKNOWN, uint16(i+1), fmt.Sprintf("%s:%d", host, 4000+i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nicInfos = append(nicInfos, nicInfo)
+	}
+	return nicInfos
+}
+
+// Advertised addresses are limited by the packet size and NumPath
+func TestFitNicInfos(t *testing.T) {
+	tests := []struct {
+		name     string
+		num      int
+		host     string
+		maxLen   int
+		expected int
+	}{
+		{"few", 3, "127.0.0.1", PACKET_SIZE, 3},
+		{"ipv6 over packet size", 100, "[::1]", PACKET_SIZE, (PACKET_SIZE - HELLO_ACK_PACKET_HEADER_LEN) / (NIC_INFO_HEADER_LEN + 16)},
+		{"over num path", 300, "127.0.0.1", 65535, MAX_NIC_INFOS},
+		{"none", 1, "127.0.0.1", HELLO_ACK_PACKET_HEADER_LEN, 0},
+	}
+
+	for _, test := range tests {
+		nicInfos := createNicInfos(t, test.num, test.host)
+		fit, dropped := fitNicInfos(nicInfos, test.maxLen)
+		if len(fit) != test.expected || len(fit)+len(dropped) != test.num {
+			t.Errorf("%s: %d fit and %d dropped, expected %d fit", test.name, len(fit), len(dropped), test.expected)
+			continue
+		}
+
+		// Hello ACK is framed by its length and parsed with all addresses
+		var token [RESUMPTION_TOKEN_LEN]byte
+		packet := CreateHelloAckPacket(PROTOCOL_VERSION, 1, LOCAL_CAPABILITIES, 1000, token, 0, fit)
+		b := &bytes.Buffer{}
+		packet.Write(b)
+		if b.Len() != int(packet.Length) || b.Len() > test.maxLen {
+			t.Errorf("%s: %d bytes written, length %d, max %d", test.name, b.Len(), packet.Length, test.maxLen)
+			continue
+		}
+
+		received, err := ReadPacket(b, test.maxLen)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if n := len(received.(*HelloAckPacket).NicInfos); n != len(fit) {
+			t.Errorf("%s: %d addresses are parsed, expected %d", test.name, n, len(fit))
+		}
+	}
+}
+
+// Loopback addresses are advertised only to a peer connected over loopback
+func TestGetNicInfoLoopback(t *testing.T) {
+	tests := []struct {
+		peerAddr string
+		expected []string
+	}{
+		{"198.51.100.1:5000", []string{"192.0.2.1:4242"}},
+		{"[2001:db8::2]:5000", []string{"192.0.2.1:4242"}},
+		{"127.0.0.1:5000", []string{"127.0.0.1:4242", "192.0.2.1:4242", "[::1]:4242"}},
+		{"[::1]:5000", []string{"127.0.0.1:4242", "192.0.2.1:4242", "[::1]:4242"}},
+	}
+
+	for _, test := range tests {
+		config := DefaultConfig()
+		config.Verbose = false
+		config.ListenAddrs = []string{"127.0.0.1:4242", "192.0.2.1:4242", "[::1]:4242"}
+
+		s := CreateSession(1, config, nil)
+		s.connectedAddrList = append(s.connectedAddrList, test.peerAddr)
+
+		nicInfos := s.getNicInfo()
+		addrList := make([]string, len(nicInfos))
+		for i := range nicInfos {
+			addrList[i] = nicInfos[i].String()
+		}
+		if fmt.Sprint(addrList) != fmt.Sprint(test.expected) {
+			t.Errorf("peer %s: advertised %v, expected %v", test.peerAddr, addrList, test.expected)
+		}
+
+		err := s.sendAddPathPacket("127.0.0.1:4343")
+		if isLoopbackAddr(test.peerAddr) == errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("peer %s: Add Path of a loopback address: %v", test.peerAddr, err)
+		}
+
+		s.teardown()
+	}
+}
@@ -2,21 +2,39 @@ package multipath
 
 import (
 	"bytes"
+	"io"
 )
 
-const HELLO_PACKET_HEADER_LEN = 7 // header length of hello packet
+const HELLO_PACKET_HEADER_LEN = 50 // header length of hello packet
 
+const PATH_MAC_LEN = 32 // HMAC-SHA256
+
+// Version, Capabilities and MaxPayloadSize are those of the initiator (see negotiateProtocol())
+// Fields of newer versions are appended, so that older peers can parse Hello
+// PathMac and AckSeqNumber are zero for the first path of a session
+// For an additional path or a resumed session, PathMac authenticates the path (see computePathMac())
+// and AckSeqNumber is the next sequence number to be delivered (see RecvBuffer)
 type HelloPacket struct {
-	Type      byte
-	Length    uint16
-	SessionID uint32
+	Type           byte
+	Length         uint16
+	Version        byte
+	SessionID      uint32
+	Capabilities   uint32
+	MaxPayloadSize uint16 // maximum payload size of data packets which the initiator can receive
+	PathMac        [PATH_MAC_LEN]byte
+	AckSeqNumber   uint32
 }
 
-func CreateHelloPacket(sessionID uint32) *HelloPacket {
+func CreateHelloPacket(sessionID uint32, capabilities uint32, maxPayloadSize uint16, pathMac [PATH_MAC_LEN]byte, ackSeq uint32) *HelloPacket {
 	packet := HelloPacket{}
 	packet.Type = HELLO_PACKET
 	packet.Length = HELLO_PACKET_HEADER_LEN
+	packet.Version = PROTOCOL_VERSION
 	packet.SessionID = sessionID
+	packet.Capabilities = capabilities
+	packet.MaxPayloadSize = maxPayloadSize
+	packet.PathMac = pathMac
+	packet.AckSeqNumber = ackSeq
 	return &packet
 }
 
@@ -32,23 +50,65 @@ func ParseHelloPacket(r *bytes.Reader) (*HelloPacket, error) {
 		return nil, err
 	}
 
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
 	sessionID, err := ReadUint32(r)
 	if err != nil {
 		return nil, err
 	}
 
+	capabilities, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	maxPayloadSize, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
 	packet := &HelloPacket{}
+	_, err = io.ReadFull(r, packet.PathMac[:])
+	if err != nil {
+		return nil, err
+	}
+
+	ackSeq, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
 	packet.Type = packetType
 	packet.Length = packetLegnth
+	if packet.Length > HELLO_PACKET_HEADER_LEN {
+		// Fields appended by newer versions are ignored
+		packet.Length = HELLO_PACKET_HEADER_LEN
+	}
+	packet.Version = version
 	packet.SessionID = sessionID
+	packet.Capabilities = capabilities
+	packet.MaxPayloadSize = maxPayloadSize
+	packet.AckSeqNumber = ackSeq
 
 	return packet, nil
 }
 
+func (p *HelloPacket) GetType() byte {
+	return p.Type
+}
+
 // Writes Hello packet
 func (p *HelloPacket) Write(b *bytes.Buffer) error {
 	b.WriteByte(p.Type)
 	WriteUint16(b, uint16(p.Length))
+	b.WriteByte(p.Version)
 	WriteUint32(b, uint32(p.SessionID))
+	WriteUint32(b, p.Capabilities)
+	WriteUint16(b, p.MaxPayloadSize)
+	b.Write(p.PathMac[:])
+	WriteUint32(b, p.AckSeqNumber)
 	return nil
 }
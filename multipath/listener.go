@@ -0,0 +1,67 @@
+package multipath
+
+import (
+	"context"
+	"net"
+)
+
+// net.Listener which accepts multipath sessions of SessionManager
+// e.g. grpcServer.Serve(multipath.CreateListener(sessionManager))
+type Listener struct {
+	manager *SessionManager
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func CreateListener(manager *SessionManager) *Listener {
+	l := Listener{
+		manager: manager,
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+
+	return &l
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	sess, err := l.manager.Accept(l.ctx, nil)
+	if err != nil {
+		if l.ctx.Err() != nil {
+			return nil, net.ErrClosed
+		}
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+// Stop returning sessions from Accept()
+// Accepted sessions are not closed
+func (l *Listener) Close() error {
+	if l.ctx.Err() != nil {
+		return net.ErrClosed
+	}
+	l.cancel()
+
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return &SessionAddr{AddrList: l.manager.getListenAddrs()}
+}
+
+// Dial function for multipath session (e.g. grpc.WithContextDialer(sessionManager.DialContext))
+// ctx bounds the handshake of the first path (e.g. connect timeout of gRPC)
+// (additional paths are connected in background until the session is closed, since ctx may be done after the dial)
+func (m *SessionManager) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sess, err := m.connectContext(ctx, addr, nil)
+	if err != nil {
+		// Avoid a non-nil net.Conn holding a nil *Session
+		return nil, err
+	}
+
+	return sess, nil
+}
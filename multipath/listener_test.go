@@ -0,0 +1,73 @@
+package multipath
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// Handshake with a peer which does not answer is bounded by ctx
+func TestDialContextDeadline(t *testing.T) {
+	config := DefaultConfig()
+	config.ListenAddrs = []string{"127.0.0.1:0"}
+	config.Verbose = false
+
+	m, err := CreateSessionManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := m.DialContext(ctx, silent.LocalAddr().String())
+	if err == nil {
+		conn.Close()
+		t.Fatal("connected to a silent peer")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("dial took %v after the deadline of 200ms", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v is not context.DeadlineExceeded", err)
+	}
+}
+
+// Additional paths are not bounded by the dial context, but stop when the session is closed
+func TestConnectNicStopsOnClose(t *testing.T) {
+	config := DefaultConfig()
+	config.Verbose = false
+
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	nicInfo, err := CreateNicInfo(NIC_TYPE_UNKNOWN, 1, silent.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := CreateSession(1, config, nil)
+	time.AfterFunc(200*time.Millisecond, s.teardown)
+
+	start := time.Now()
+	err = s.connectNic(s.ctx, []NicInfo{nicInfo})
+	if err == nil {
+		t.Fatal("connected to a silent peer")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("connect took %v after the session is closed", elapsed)
+	}
+}
@@ -0,0 +1,67 @@
+package multipath
+
+import (
+	"time"
+)
+
+// Lowest RTT first scheduler (SCHED_LOWEST_RTT)
+// Every packet is sent through the available path with the lowest smoothed RTT.
+// RTT includes queueing delay on the sender, so a loaded path gives way to the others.
+type LowestRttScheduler struct {
+	schedulerBase
+}
+
+func CreateLowestRttScheduler() *LowestRttScheduler {
+	c := LowestRttScheduler{}
+
+	return &c
+}
+
+func (c *LowestRttScheduler) SetNumPath(numPath int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setNumPath(numPath)
+}
+
+func (c *LowestRttScheduler) SetPathAvailable(pathID int, available bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setPathAvailable(pathID, available)
+}
+
+func (c *LowestRttScheduler) UpdatePathCondition(pathID int, rtt time.Duration, ackedBytes uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.updateRTT(pathID, rtt)
+}
+
+func (c *LowestRttScheduler) Scheduling(payloadSize uint32) []int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	selectedPath := -1
+	for i := 0; i < c.numPath; i++ {
+		if !c.pathAvailable[i] {
+			continue
+		}
+
+		// Path whose RTT is not measured yet is selected first to be measured
+		if c.smoothedRTT[i] == 0 {
+			selectedPath = i
+			break
+		}
+
+		if selectedPath < 0 || c.smoothedRTT[i] < c.smoothedRTT[selectedPath] {
+			selectedPath = i
+		}
+	}
+
+	if selectedPath < 0 {
+		return nil
+	}
+
+	return []int{selectedPath}
+}
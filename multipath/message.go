@@ -0,0 +1,64 @@
+package multipath
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const MESSAGE_HEADER_LEN = 4 // length of message
+
+// Message-oriented API on top of byte stream of Session
+// A message is framed as [length (4 bytes, big endian)][message]
+// Do not mix with Read()/Write() on the same session, which breaks the framing
+
+// Send a message
+func (s *Session) WriteMessage(msg []byte) error {
+	if uint64(len(msg)) > 0xFFFFFFFF {
+		return fmt.Errorf("%w (%d bytes)", ErrMessageTooLarge, len(msg))
+	}
+
+	header := make([]byte, MESSAGE_HEADER_LEN)
+	binary.BigEndian.PutUint32(header, uint32(len(msg)))
+
+	// Header and message should not be interleaved with other writes
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	_, err := s.write(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.write(msg)
+	return err
+}
+
+// Receive a whole message
+// Returns io.EOF if the session is closed between messages, and io.ErrUnexpectedEOF in the middle of a message
+func (s *Session) ReadMessage() ([]byte, error) {
+	s.readMutex.Lock()
+	defer s.readMutex.Unlock()
+
+	header := make([]byte, MESSAGE_HEADER_LEN)
+	_, err := io.ReadFull(s, header)
+	if err != nil {
+		return nil, err
+	}
+
+	msgLen := binary.BigEndian.Uint32(header)
+	if uint64(msgLen) > uint64(s.config.MaxMsgSize) {
+		return nil, fmt.Errorf("%w (%d bytes > %d bytes)", ErrMessageTooLarge, msgLen, s.config.MaxMsgSize)
+	}
+
+	msg := make([]byte, msgLen)
+	_, err = io.ReadFull(s, msg)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
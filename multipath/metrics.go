@@ -0,0 +1,124 @@
+package multipath
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const METRICS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8" // Prometheus text format
+
+// HTTP handler exporting SessionManager.Stats() in Prometheus text format
+// e.g. http.Handle("/metrics", sessionManager.MetricsHandler())
+func (m *SessionManager) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", METRICS_CONTENT_TYPE)
+		err := m.WriteMetrics(w)
+		if err != nil {
+			m.logger.Log("SessionManager.MetricsHandler(): %v", err)
+		}
+	})
+}
+
+// Write metrics of all sessions in Prometheus text format
+func (m *SessionManager) WriteMetrics(w io.Writer) error {
+	stats := m.Stats()
+	b := bufio.NewWriter(w)
+
+	// Session manager
+	writeMetric(b, "mp2bs_sessions", "gauge", "Number of live multipath sessions", nil, float64(stats.NumSession))
+	writeMetric(b, "mp2bs_active_paths", "gauge", "Number of active paths of all sessions", nil, float64(stats.NumActivePath))
+	writeMetric(b, "mp2bs_reorder_buffer_packets", "gauge", "Packets waiting in reorder buffers of all sessions", nil, float64(stats.ReorderBufferLen))
+	writeMetric(b, "mp2bs_sent_bytes_total", "counter", "Payload bytes of sent data packets", nil, float64(stats.SentBytes))
+	writeMetric(b, "mp2bs_received_bytes_total", "counter", "Payload bytes of received data packets", nil, float64(stats.RecvBytes))
+	writeMetric(b, "mp2bs_sent_packets_total", "counter", "Sent data packets including reinjected ones", nil, float64(stats.SentPackets))
+	writeMetric(b, "mp2bs_received_packets_total", "counter", "Received data packets", nil, float64(stats.RecvPackets))
+	writeMetric(b, "mp2bs_reinjected_packets_total", "counter", "Data packets reinjected from a lost path", nil, float64(stats.ReinjectedPackets))
+	writeMetric(b, "mp2bs_reordered_packets_total", "counter", "Data packets received out of order", nil, float64(stats.ReorderedPackets))
+	writeMetric(b, "mp2bs_handshake_failures_total", "counter", "Paths which failed to connect or were rejected", nil, float64(stats.HandshakeFailures))
+	writeMetric(b, "mp2bs_path_up_total", "counter", "Path up transitions", nil, float64(stats.PathUps))
+	writeMetric(b, "mp2bs_path_down_total", "counter", "Path down transitions", nil, float64(stats.PathDowns))
+
+	// Sessions
+	writeHeader(b, "mp2bs_session_reorder_buffer_packets", "gauge", "Packets waiting in the reorder buffer of a session")
+	for _, sessStats := range stats.Sessions {
+		labels := []string{"session_id", strconv.FormatUint(uint64(sessStats.SessionID), 10)}
+		writeSample(b, "mp2bs_session_reorder_buffer_packets", labels, float64(sessStats.ReorderBufferLen))
+	}
+
+	// Paths
+	pathMetrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(p *PathStats) float64
+	}{
+		{"mp2bs_path_active", "gauge", "Whether the path is active (1) or not (0)", func(p *PathStats) float64 {
+			if p.Status == PATH_ACTIVE {
+				return 1
+			}
+			return 0
+		}},
+		{"mp2bs_path_sent_bytes_total", "counter", "Payload bytes of data packets sent through the path", func(p *PathStats) float64 { return float64(p.SentBytes) }},
+		{"mp2bs_path_received_bytes_total", "counter", "Payload bytes of data packets received through the path", func(p *PathStats) float64 { return float64(p.RecvBytes) }},
+		{"mp2bs_path_reinjected_packets_total", "counter", "Data packets reinjected from the path", func(p *PathStats) float64 { return float64(p.ReinjectedPackets) }},
+		{"mp2bs_path_lost_packets_total", "counter", "QUIC packets of the path declared lost", func(p *PathStats) float64 { return float64(p.LostPackets) }},
+		{"mp2bs_path_rtt_seconds", "gauge", "Smoothed RTT of the path measured by QUIC", func(p *PathStats) float64 { return p.RTT.Seconds() }},
+		{"mp2bs_path_cwnd_bytes", "gauge", "Congestion window of the path", func(p *PathStats) float64 { return float64(p.Cwnd) }},
+	}
+
+	for _, metric := range pathMetrics {
+		writeHeader(b, metric.name, metric.kind, metric.help)
+		for _, sessStats := range stats.Sessions {
+			for i := range sessStats.Paths {
+				path := &sessStats.Paths[i]
+				labels := []string{
+					"session_id", strconv.FormatUint(uint64(sessStats.SessionID), 10),
+					"path_id", strconv.Itoa(path.PathID),
+					"local_addr", path.LocalAddr,
+					"remote_addr", path.RemoteAddr,
+				}
+				writeSample(b, metric.name, labels, metric.value(path))
+			}
+		}
+	}
+
+	return b.Flush()
+}
+
+func writeMetric(b *bufio.Writer, name string, kind string, help string, labels []string, value float64) {
+	writeHeader(b, name, kind, help)
+	writeSample(b, name, labels, value)
+}
+
+func writeHeader(b *bufio.Writer, name string, kind string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+}
+
+// labels are pairs of label name and value
+func writeSample(b *bufio.Writer, name string, labels []string, value float64) {
+	b.WriteString(name)
+
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+
+	fmt.Fprintf(b, " %s\n", strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+var labelValueEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
@@ -0,0 +1,122 @@
+package multipath
+
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Type of network interface (NicInfo.Type)
+const (
+	NIC_TYPE_UNKNOWN  = 0
+	NIC_TYPE_ETHERNET = 1
+	NIC_TYPE_WIRELESS = 2
+	NIC_TYPE_CELLULAR = 3
+	NIC_TYPE_LOOPBACK = 4
+)
+
+const NIC_MONITOR_INTERVAL = 3 * time.Second // interval for detecting added or removed interfaces
+
+// Interface name prefixes (Linux, BSD, macOS, Android and iOS)
+var wirelessNicPrefixes = []string{"wl", "wifi", "ath", "ra", "awdl"}
+var cellularNicPrefixes = []string{"wwan", "rmnet", "ccmni", "pdp_ip", "usb", "ppp"}
+var ethernetNicPrefixes = []string{"eth", "en", "em", "bond"}
+
+// Usable address of a local network interface
+type localNic struct {
+	name    string
+	nicType byte
+	ip      net.IP
+}
+
+// Enumerate usable addresses of up interfaces
+// Link-local addresses are excluded since they cannot be used without the zone
+func discoverNics() ([]localNic, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	nics := make([]localNic, 0)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		nicType := getNicType(iface)
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLinkLocalUnicast() || ipNet.IP.IsMulticast() {
+				continue
+			}
+			nics = append(nics, localNic{name: iface.Name, nicType: nicType, ip: ipNet.IP})
+		}
+	}
+
+	return nics, nil
+}
+
+// Classify a network interface by its flags and name
+func getNicType(iface net.Interface) byte {
+	if iface.Flags&net.FlagLoopback != 0 {
+		return NIC_TYPE_LOOPBACK
+	}
+
+	// Linux exposes wireless interfaces in sysfs
+	if _, err := os.Stat("/sys/class/net/" + iface.Name + "/wireless"); err == nil {
+		return NIC_TYPE_WIRELESS
+	}
+
+	name := strings.ToLower(iface.Name)
+	switch {
+	case hasAnyPrefix(name, wirelessNicPrefixes):
+		return NIC_TYPE_WIRELESS
+	case hasAnyPrefix(name, cellularNicPrefixes):
+		return NIC_TYPE_CELLULAR
+	case hasAnyPrefix(name, ethernetNicPrefixes):
+		return NIC_TYPE_ETHERNET
+	}
+
+	return NIC_TYPE_UNKNOWN
+}
+
+// Whether host:port is a loopback address
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func NicTypeString(nicType byte) string {
+	switch nicType {
+	case NIC_TYPE_ETHERNET:
+		return "ethernet"
+	case NIC_TYPE_WIRELESS:
+		return "wireless"
+	case NIC_TYPE_CELLULAR:
+		return "cellular"
+	case NIC_TYPE_LOOPBACK:
+		return "loopback"
+	default:
+		return "unknown"
+	}
+}
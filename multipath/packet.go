@@ -0,0 +1,180 @@
+package multipath
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const PACKET_PREFIX_LEN = 3    // type and length, common to all packet types
+const HELLO_VERSION_OFFSET = 3 // version follows the prefix in Hello
+
+// Packet of multipath session
+type Packet interface {
+	GetType() byte
+	Write(b *bytes.Buffer) error
+}
+
+var (
+	_ Packet = (*HelloPacket)(nil)
+	_ Packet = (*HelloAckPacket)(nil)
+	_ Packet = (*DataPacket)(nil)
+	_ Packet = (*GoodbyePacket)(nil)
+	_ Packet = (*AckPacket)(nil)
+	_ Packet = (*AddPathPacket)(nil)
+	_ Packet = (*RemovePathPacket)(nil)
+	_ Packet = (*PingPacket)(nil)
+	_ Packet = (*GoodbyeAckPacket)(nil)
+)
+
+// Minimum length of each packet type (Hello, Hello ACK, Data and Add Path have variable length)
+var packetHeaderLen = map[byte]int{
+	HELLO_PACKET:       HELLO_PACKET_HEADER_LEN,
+	HELLO_ACK_PACKET:   HELLO_ACK_PACKET_HEADER_LEN,
+	DATA_PACKET:        DATA_PACKET_HEADER_LEN,
+	GOODBYE_PACKET:     GOODBYE_PACKET_HEADER_LEN,
+	ACK_PACKET:         ACK_PACKET_HEADER_LEN,
+	ADD_PATH_PACKET:    ADD_PATH_PACKET_HEADER_LEN,
+	REMOVE_PATH_PACKET: REMOVE_PATH_PACKET_HEADER_LEN,
+	PING_PACKET:        PING_PACKET_HEADER_LEN,
+	PONG_PACKET:        PING_PACKET_HEADER_LEN,
+	GOODBYE_ACK_PACKET: GOODBYE_ACK_PACKET_HEADER_LEN,
+}
+
+// Read a packet from a stream
+// Reads are repeated until the whole packet is received (QUIC stream may return fewer bytes),
+// and packets longer than maxLen (e.g. Config.PacketSize) are refused
+func ReadPacket(r io.Reader, maxLen int) (Packet, error) {
+	prefix := make([]byte, PACKET_PREFIX_LEN)
+	_, err := io.ReadFull(r, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	packetType := prefix[0]
+	packetLength := int(prefix[1])<<8 | int(prefix[2])
+
+	// Hello of an incompatible version is rejected by its version rather than its length
+	if packetType == HELLO_PACKET {
+		version := make([]byte, 1)
+		_, err = io.ReadFull(r, version)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkHelloVersion(version[0])
+		if err != nil {
+			return nil, err
+		}
+		prefix = append(prefix, version[0])
+	}
+
+	err = checkPacketLength(packetType, packetLength, maxLen)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, packetLength)
+	copy(buf, prefix)
+	_, err = io.ReadFull(r, buf[len(prefix):])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePacket(buf)
+}
+
+// Parse a packet of any type from buf (bytes after the packet length are ignored)
+func ParsePacket(buf []byte) (Packet, error) {
+	if len(buf) < PACKET_PREFIX_LEN {
+		return nil, fmt.Errorf("%w: packet is too short (%d)", ErrInvalidPacket, len(buf))
+	}
+
+	packetType := buf[0]
+	packetLength := int(buf[1])<<8 | int(buf[2])
+
+	if packetType == HELLO_PACKET && len(buf) > HELLO_VERSION_OFFSET {
+		err := checkHelloVersion(buf[HELLO_VERSION_OFFSET])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := checkPacketLength(packetType, packetLength, len(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	var packet Packet
+	r := bytes.NewReader(buf[:packetLength])
+
+	switch packetType {
+	case HELLO_PACKET:
+		packet, err = ParseHelloPacket(r)
+	case HELLO_ACK_PACKET:
+		packet, err = ParseHelloAckPacket(r)
+	case DATA_PACKET:
+		packet, err = ParseDataPacket(r)
+	case GOODBYE_PACKET:
+		packet, err = ParseGoodbyePacket(r)
+	case ACK_PACKET:
+		packet, err = ParseAckPacket(r)
+	case ADD_PATH_PACKET:
+		packet, err = ParseAddPathPacket(r)
+	case REMOVE_PATH_PACKET:
+		packet, err = ParseRemovePathPacket(r)
+	case PING_PACKET, PONG_PACKET:
+		packet, err = ParsePingPacket(r)
+	case GOODBYE_ACK_PACKET:
+		packet, err = ParseGoodbyeAckPacket(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPacket, err)
+	}
+
+	// Only Hello is extended by newer versions (other packets follow the negotiated version)
+	if packetType != HELLO_PACKET && r.Len() > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes in packet type (%d)", ErrInvalidPacket, r.Len(), packetType)
+	}
+
+	return packet, nil
+}
+
+// Write a packet to a stream at once (packets of a stream should not be interleaved)
+func WritePacket(w io.Writer, packet Packet) error {
+	b := &bytes.Buffer{}
+	err := packet.Write(b)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// Layout of Hello depends on its version, so the version is checked before the length
+// (peers of an old version get ErrIncompatiblePeer instead of ErrInvalidPacket)
+func checkHelloVersion(version byte) error {
+	_, err := negotiateVersion(version)
+	return err
+}
+
+// Packet length should cover the header of the packet type
+func checkPacketLength(packetType byte, packetLength int, maxLen int) error {
+	headerLen, exists := packetHeaderLen[packetType]
+	if !exists {
+		return fmt.Errorf("%w (%d)", ErrUnknownPacketType, packetType)
+	}
+
+	if packetLength < headerLen || packetLength > maxLen {
+		return fmt.Errorf("%w: packet length (%d) of packet type (%d)", ErrInvalidPacket, packetLength, packetType)
+	}
+
+	return nil
+}
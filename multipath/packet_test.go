@@ -0,0 +1,219 @@
+package multipath
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// One packet of each type
+func samplePackets(t testing.TB) []Packet {
+	var pathMac [PATH_MAC_LEN]byte
+	var token [RESUMPTION_TOKEN_LEN]byte
+	for i := range pathMac {
+		pathMac[i] = byte(i)
+	}
+	for i := range token {
+		token[i] = byte(0xF0 + i)
+	}
+
+	nicInfo4, err := CreateNicInfo(NIC_TYPE_UNKNOWN, 1, "192.0.2.1:4242")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nicInfo6, err := CreateNicInfo(NIC_TYPE_UNKNOWN, 1, "[2001:db8::1]:4242")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []Packet{
+		CreateHelloPacket(0x01020304, LOCAL_CAPABILITIES, 1488, pathMac, 0xFFFFFFFE),
+		CreateHelloAckPacket(PROTOCOL_VERSION, 0x01020304, LOCAL_CAPABILITIES, 1488, token, 7, []NicInfo{nicInfo4, nicInfo6}),
+		CreateHelloAckPacket(PROTOCOL_VERSION, 0x01020304, LOCAL_CAPABILITIES, 1488, token, 7, []NicInfo{}),
+		CreateDataPacket(0x01020304, 1, 0xFFFFFFFF, []byte("payload")),
+		CreateDataPacket(0x01020304, 0, 0, []byte{}),
+		CreateGoodbyePacket(0x01020304, 42),
+		CreateAckPacket(0x01020304, 42),
+		CreateAddPathPacket(0x01020304, nicInfo6),
+		CreateRemovePathPacket(0x01020304),
+		CreatePingPacket(0x01020304, 3),
+		CreatePongPacket(0x01020304, 3),
+		CreateGoodbyeAckPacket(0x01020304),
+	}
+}
+
+func encodePacket(t testing.TB, packet Packet) []byte {
+	b := &bytes.Buffer{}
+	err := WritePacket(b, packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// A parsed packet is written with its declared length and parsed again as the same packet
+func checkRoundTrip(t testing.TB, packet Packet) {
+	encoded := encodePacket(t, packet)
+	if len(encoded) < PACKET_PREFIX_LEN {
+		t.Fatalf("packet type (%d) is encoded in %d bytes", packet.GetType(), len(encoded))
+	}
+	if length := int(encoded[1])<<8 | int(encoded[2]); length != len(encoded) {
+		t.Fatalf("packet type (%d) declares length %d, but %d bytes are written", packet.GetType(), length, len(encoded))
+	}
+
+	parsed, err := ParsePacket(encoded)
+	if err != nil {
+		t.Fatalf("packet type (%d) is not parsed after writing: %v", packet.GetType(), err)
+	}
+	if !reflect.DeepEqual(packet, parsed) {
+		t.Fatalf("packet type (%d) differs after writing: %+v, %+v", packet.GetType(), packet, parsed)
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	for _, packet := range samplePackets(t) {
+		checkRoundTrip(t, packet)
+	}
+}
+
+// QUIC streams may return fewer bytes than requested
+func TestReadPacketShortReads(t *testing.T) {
+	packets := samplePackets(t)
+
+	stream := &bytes.Buffer{}
+	for _, packet := range packets {
+		stream.Write(encodePacket(t, packet))
+	}
+
+	r := iotest.OneByteReader(stream)
+	for _, packet := range packets {
+		received, err := ReadPacket(r, PACKET_SIZE)
+		if err != nil {
+			t.Fatalf("packet type (%d): %v", packet.GetType(), err)
+		}
+		if !reflect.DeepEqual(packet, received) {
+			t.Fatalf("packet type (%d) differs: %+v, %+v", packet.GetType(), packet, received)
+		}
+	}
+
+	if _, err := ReadPacket(r, PACKET_SIZE); err != io.EOF {
+		t.Fatalf("end of stream: %v", err)
+	}
+}
+
+func TestReadPacketTruncated(t *testing.T) {
+	encoded := encodePacket(t, CreateDataPacket(1, 0, 1, []byte("payload")))
+
+	for n := 1; n < len(encoded); n++ {
+		_, err := ReadPacket(iotest.OneByteReader(bytes.NewReader(encoded[:n])), PACKET_SIZE)
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("packet truncated to %d bytes: %v", n, err)
+		}
+	}
+}
+
+func TestReadPacketLength(t *testing.T) {
+	encoded := encodePacket(t, CreateDataPacket(1, 0, 1, make([]byte, 100)))
+
+	// Longer than maxLen
+	_, err := ReadPacket(bytes.NewReader(encoded), len(encoded)-1)
+	if !errors.Is(err, ErrInvalidPacket) {
+		t.Fatalf("packet longer than maxLen: %v", err)
+	}
+
+	// Shorter than the header of the packet type
+	short := []byte{ACK_PACKET, 0, ACK_PACKET_HEADER_LEN - 1}
+	_, err = ReadPacket(bytes.NewReader(append(short, make([]byte, ACK_PACKET_HEADER_LEN)...)), PACKET_SIZE)
+	if !errors.Is(err, ErrInvalidPacket) {
+		t.Fatalf("packet shorter than header: %v", err)
+	}
+
+	// Unknown packet type
+	_, err = ReadPacket(bytes.NewReader([]byte{0xEE, 0, 3}), PACKET_SIZE)
+	if !errors.Is(err, ErrUnknownPacketType) {
+		t.Fatalf("unknown packet type: %v", err)
+	}
+}
+
+// Hello of an incompatible version is rejected by its version, whatever its length
+func TestReadPacketIncompatibleHello(t *testing.T) {
+	// Hello without version (session ID follows the length)
+	oldHello := []byte{HELLO_PACKET, 0, 19, 0, 0, 0, 0}
+	oldHello = append(oldHello, make([]byte, 12)...)
+
+	_, err := ReadPacket(iotest.OneByteReader(bytes.NewReader(oldHello)), PACKET_SIZE)
+	if !errors.Is(err, ErrIncompatiblePeer) {
+		t.Fatalf("ReadPacket(): %v", err)
+	}
+
+	_, err = ParsePacket(oldHello)
+	if !errors.Is(err, ErrIncompatiblePeer) {
+		t.Fatalf("ParsePacket(): %v", err)
+	}
+}
+
+// Fields appended to Hello by newer versions are ignored
+func TestReadPacketExtendedHello(t *testing.T) {
+	var pathMac [PATH_MAC_LEN]byte
+	hello := CreateHelloPacket(1, LOCAL_CAPABILITIES, 1488, pathMac, 0)
+	hello.Version = PROTOCOL_VERSION + 1
+
+	encoded := encodePacket(t, hello)
+	extended := append(encoded, 0xAA, 0xBB, 0xCC)
+	extended[1], extended[2] = 0, byte(len(extended))
+
+	received, err := ReadPacket(bytes.NewReader(extended), PACKET_SIZE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hello, received) {
+		t.Fatalf("Hello differs: %+v, %+v", hello, received)
+	}
+
+	// Other packet types follow the negotiated version
+	encoded = encodePacket(t, CreateGoodbyeAckPacket(1))
+	encoded = append(encoded, 0xAA)
+	encoded[2]++
+	_, err = ParsePacket(encoded)
+	if !errors.Is(err, ErrInvalidPacket) {
+		t.Fatalf("trailing bytes: %v", err)
+	}
+}
+
+func FuzzParsePacket(f *testing.F) {
+	for _, packet := range samplePackets(f) {
+		f.Add(encodePacket(f, packet))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		packet, err := ParsePacket(data)
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, packet)
+	})
+}
+
+func FuzzReadPacket(f *testing.F) {
+	stream := &bytes.Buffer{}
+	for _, packet := range samplePackets(f) {
+		encoded := encodePacket(f, packet)
+		f.Add(encoded)
+		stream.Write(encoded)
+	}
+	f.Add(stream.Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := iotest.OneByteReader(bytes.NewReader(data))
+		for {
+			packet, err := ReadPacket(r, PACKET_SIZE)
+			if err != nil {
+				return
+			}
+			checkRoundTrip(t, packet)
+		}
+	})
+}
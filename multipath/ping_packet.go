@@ -0,0 +1,74 @@
+package multipath
+
+import (
+	"bytes"
+)
+
+const PING_PACKET_HEADER_LEN = 11 // header length of ping (and pong) packet
+
+// Keepalive probe of a path (PING_PACKET) and its reply (PONG_PACKET)
+// Pong echoes the sequence number of ping, so the sender measures RTT of the path
+type PingPacket struct {
+	Type      byte
+	Length    uint16
+	SessionID uint32
+	SeqNumber uint32
+}
+
+func CreatePingPacket(sessionID uint32, seq uint32) *PingPacket {
+	packet := PingPacket{}
+	packet.Type = PING_PACKET
+	packet.Length = PING_PACKET_HEADER_LEN
+	packet.SessionID = sessionID
+	packet.SeqNumber = seq
+	return &packet
+}
+
+func CreatePongPacket(sessionID uint32, seq uint32) *PingPacket {
+	packet := CreatePingPacket(sessionID, seq)
+	packet.Type = PONG_PACKET
+	return packet
+}
+
+func ParsePingPacket(r *bytes.Reader) (*PingPacket, error) {
+	packetType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packetLegnth, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	seqNumber, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := &PingPacket{}
+	packet.Type = packetType
+	packet.Length = packetLegnth
+	packet.SessionID = sessionID
+	packet.SeqNumber = seqNumber
+
+	return packet, nil
+}
+
+func (p *PingPacket) GetType() byte {
+	return p.Type
+}
+
+// Writes Ping (or Pong) Packet
+func (p *PingPacket) Write(b *bytes.Buffer) error {
+	b.WriteByte(p.Type)
+	WriteUint16(b, uint16(p.Length))
+	WriteUint32(b, uint32(p.SessionID))
+	WriteUint32(b, uint32(p.SeqNumber))
+	return nil
+}
@@ -0,0 +1,128 @@
+package multipath
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+// Metrics of a QUIC connection (quic.Connection does not expose RTT and congestion window)
+type quicMetrics struct {
+	smoothedRTT   time.Duration
+	minRTT        time.Duration
+	cwnd          uint64 // congestion window (bytes)
+	bytesInFlight uint64
+	lostPackets   uint64 // QUIC packets declared lost (retransmitted by QUIC)
+}
+
+// Tracer of all QUIC connections of the process (quic.Config.Tracer)
+var quicTracer = &metricsTracer{connMap: make(map[uint64]*metricsConnTracer)}
+
+type metricsTracer struct {
+	mutex   sync.Mutex
+	connMap map[uint64]*metricsConnTracer // tracer of each connection by quic.ConnectionTracingKey
+}
+
+func (t *metricsTracer) TracerForConnection(ctx context.Context, p logging.Perspective, odcid logging.ConnectionID) logging.ConnectionTracer {
+	tracingID, ok := ctx.Value(quic.ConnectionTracingKey).(uint64)
+	if !ok {
+		return nil
+	}
+
+	connTracer := &metricsConnTracer{tracer: t, tracingID: tracingID}
+
+	t.mutex.Lock()
+	t.connMap[tracingID] = connTracer
+	t.mutex.Unlock()
+
+	return connTracer
+}
+
+func (t *metricsTracer) SentPacket(net.Addr, *logging.Header, logging.ByteCount, []logging.Frame) {}
+
+func (t *metricsTracer) DroppedPacket(net.Addr, logging.PacketType, logging.ByteCount, logging.PacketDropReason) {
+}
+
+// Metrics of the QUIC connection (zero value after the connection is closed)
+func (t *metricsTracer) getMetrics(conn quic.Connection) quicMetrics {
+	tracingID, ok := conn.Context().Value(quic.ConnectionTracingKey).(uint64)
+	if !ok {
+		return quicMetrics{}
+	}
+
+	t.mutex.Lock()
+	connTracer, exists := t.connMap[tracingID]
+	t.mutex.Unlock()
+
+	if !exists {
+		return quicMetrics{}
+	}
+
+	connTracer.mutex.Lock()
+	defer connTracer.mutex.Unlock()
+
+	return connTracer.metrics
+}
+
+// Only metrics are recorded (the other events are ignored)
+type metricsConnTracer struct {
+	tracer    *metricsTracer
+	tracingID uint64
+	mutex     sync.Mutex
+	metrics   quicMetrics
+}
+
+func (c *metricsConnTracer) UpdatedMetrics(rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount, packetsInFlight int) {
+	c.mutex.Lock()
+	c.metrics.smoothedRTT = rttStats.SmoothedRTT()
+	c.metrics.minRTT = rttStats.MinRTT()
+	c.metrics.cwnd = uint64(cwnd)
+	c.metrics.bytesInFlight = uint64(bytesInFlight)
+	c.mutex.Unlock()
+}
+
+func (c *metricsConnTracer) LostPacket(logging.EncryptionLevel, logging.PacketNumber, logging.PacketLossReason) {
+	c.mutex.Lock()
+	c.metrics.lostPackets++
+	c.mutex.Unlock()
+}
+
+func (c *metricsConnTracer) Close() {
+	c.tracer.mutex.Lock()
+	delete(c.tracer.connMap, c.tracingID)
+	c.tracer.mutex.Unlock()
+}
+
+func (c *metricsConnTracer) StartedConnection(local, remote net.Addr, srcConnID, destConnID logging.ConnectionID) {
+}
+func (c *metricsConnTracer) NegotiatedVersion(chosen logging.VersionNumber, clientVersions, serverVersions []logging.VersionNumber) {
+}
+func (c *metricsConnTracer) ClosedConnection(error)                                   {}
+func (c *metricsConnTracer) SentTransportParameters(*logging.TransportParameters)     {}
+func (c *metricsConnTracer) ReceivedTransportParameters(*logging.TransportParameters) {}
+func (c *metricsConnTracer) RestoredTransportParameters(*logging.TransportParameters) {}
+func (c *metricsConnTracer) ReceivedVersionNegotiationPacket(*logging.Header, []logging.VersionNumber) {
+}
+func (c *metricsConnTracer) ReceivedRetry(*logging.Header)     {}
+func (c *metricsConnTracer) BufferedPacket(logging.PacketType) {}
+func (c *metricsConnTracer) DroppedPacket(logging.PacketType, logging.ByteCount, logging.PacketDropReason) {
+}
+func (c *metricsConnTracer) SentPacket(*logging.ExtendedHeader, logging.ByteCount, *logging.AckFrame, []logging.Frame) {
+}
+func (c *metricsConnTracer) ReceivedPacket(*logging.ExtendedHeader, logging.ByteCount, []logging.Frame) {
+}
+func (c *metricsConnTracer) AcknowledgedPacket(logging.EncryptionLevel, logging.PacketNumber)   {}
+func (c *metricsConnTracer) UpdatedCongestionState(logging.CongestionState)                     {}
+func (c *metricsConnTracer) UpdatedPTOCount(value uint32)                                       {}
+func (c *metricsConnTracer) UpdatedKeyFromTLS(logging.EncryptionLevel, logging.Perspective)     {}
+func (c *metricsConnTracer) UpdatedKey(generation logging.KeyPhase, remote bool)                {}
+func (c *metricsConnTracer) DroppedEncryptionLevel(logging.EncryptionLevel)                     {}
+func (c *metricsConnTracer) DroppedKey(generation logging.KeyPhase)                             {}
+func (c *metricsConnTracer) SetLossTimer(logging.TimerType, logging.EncryptionLevel, time.Time) {}
+func (c *metricsConnTracer) LossTimerExpired(logging.TimerType, logging.EncryptionLevel)        {}
+func (c *metricsConnTracer) LossTimerCanceled()                                                 {}
+func (c *metricsConnTracer) Debug(name, msg string)                                             {}
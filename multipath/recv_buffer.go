@@ -1,16 +1,30 @@
 package multipath
 
 import (
+	"io"
+	"os"
 	"sync"
+	"time"
 )
 
+// Sequence numbers wrap around, so they are compared by seqBefore()
 type RecvBuffer struct {
 	mutex             sync.Mutex
+	cond              *sync.Cond // signaled when data arrives, buffer is closed or read deadline is changed
+	closed            bool       // no more data will be pushed
+	closedChan        chan struct{}
+	finalSeqNumber    uint32 // sequence number following the last data packet of the peer
+	finalSeqKnown     bool   // Goodbye is received
+	readDeadline      time.Time
+	deadlineTimer     *time.Timer
 	readSeqNumber     uint32
 	recvSeqNumber     uint32
 	expectedSeqNumber uint32
 	readBuffer        []byte
 	reorderBuffer     map[uint32]*DataPacket
+	reorderedPackets  uint64 // packets received out of order
+	maxReorderLen     int    // maximum number of packets in reorderBuffer
+	logger            logger // logger of the session
 }
 
 func CreateRecvBuffer() *RecvBuffer {
@@ -20,7 +34,9 @@ func CreateRecvBuffer() *RecvBuffer {
 		expectedSeqNumber: 0,
 		readBuffer:        make([]byte, 0),
 		reorderBuffer:     make(map[uint32]*DataPacket),
+		closedChan:        make(chan struct{}),
 	}
+	b.cond = sync.NewCond(&b.mutex)
 
 	return &b
 }
@@ -29,7 +45,7 @@ func CreateRecvBuffer() *RecvBuffer {
 func (b *RecvBuffer) PushPacket(packet *DataPacket) {
 	b.mutex.Lock()
 
-	Log("RecvBuffer.PushPacket(): PathID=%d, PacketSeq=%d, ExpectedSeq=%d, Len.readBuffer=%d, Len.reorderBuffer=%d",
+	b.logger.Log("RecvBuffer.PushPacket(): PathID=%d, PacketSeq=%d, ExpectedSeq=%d, Len.readBuffer=%d, Len.reorderBuffer=%d",
 		packet.PathID, packet.SeqNumber, b.expectedSeqNumber, len(b.readBuffer), len(b.reorderBuffer))
 
 	// if the received packet is in-order
@@ -50,17 +66,49 @@ func (b *RecvBuffer) PushPacket(packet *DataPacket) {
 			delete(b.reorderBuffer, b.expectedSeqNumber)
 			b.expectedSeqNumber++
 		}
-	} else { // if the received packet is out-of-order
+
+		// wake up blocked readers
+		b.cond.Broadcast()
+
+		b.closeIfComplete()
+	} else if seqBefore(b.expectedSeqNumber, packet.SeqNumber) { // if the received packet is out-of-order
 		// insert the received dpacket into reorderBuffer
+		// (a reinjected duplicate just overwrites the same entry)
+		if _, exists := b.reorderBuffer[packet.SeqNumber]; !exists {
+			b.reorderedPackets++
+		}
 		b.reorderBuffer[packet.SeqNumber] = packet
+		if len(b.reorderBuffer) > b.maxReorderLen {
+			b.maxReorderLen = len(b.reorderBuffer)
+		}
+	} else { // if the received packet is already delivered (duplicate by reinjection)
+		b.logger.Log("RecvBuffer.PushPacket(): Drop duplicate packet (PacketSeq=%d)", packet.SeqNumber)
 	}
 
 	b.mutex.Unlock()
 }
 
 // Read from readBuffer
-func (b *RecvBuffer) Read(buf []byte) int {
+// Blocks until data is available, and returns io.EOF after all data is read from the closed buffer
+func (b *RecvBuffer) Read(buf []byte) (int, error) {
 	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	for len(b.readBuffer) == 0 {
+		if b.closed {
+			return 0, io.EOF
+		}
+
+		if !b.readDeadline.IsZero() && !time.Now().Before(b.readDeadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		b.cond.Wait()
+	}
 
 	readLen := 0
 	bufLen := len(buf)
@@ -80,15 +128,96 @@ func (b *RecvBuffer) Read(buf []byte) int {
 		b.readBuffer = b.readBuffer[readLen:]
 	}
 
+	return readLen, nil
+}
+
+// Close buffer (e.g. when the session is closed)
+// Readers get io.EOF after reading remaining data
+func (b *RecvBuffer) Close() {
+	b.mutex.Lock()
+	b.close()
 	b.mutex.Unlock()
+}
 
-	return readLen
+// Close buffer after all packets before finalSeq are received (when goodbye is received)
+// Packets of different paths may arrive after goodbye
+func (b *RecvBuffer) CloseAfter(finalSeq uint32) {
+	b.mutex.Lock()
+	b.finalSeqNumber = finalSeq
+	b.finalSeqKnown = true
+	b.closeIfComplete()
+	b.mutex.Unlock()
+}
+
+// Closed when no more data will be pushed
+func (b *RecvBuffer) Closed() <-chan struct{} {
+	return b.closedChan
+}
+
+// (mutex should be held by the caller)
+func (b *RecvBuffer) closeIfComplete() {
+	if b.finalSeqKnown && !seqBefore(b.expectedSeqNumber, b.finalSeqNumber) {
+		b.close()
+	}
+}
+
+// (mutex should be held by the caller)
+func (b *RecvBuffer) close() {
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.closedChan)
+	b.cond.Broadcast()
+}
+
+// Set deadline for blocked and future Read() calls (zero value means no deadline)
+func (b *RecvBuffer) SetReadDeadline(t time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.readDeadline = t
+
+	if b.deadlineTimer != nil {
+		b.deadlineTimer.Stop()
+		b.deadlineTimer = nil
+	}
+
+	// wake up blocked readers when the deadline is exceeded
+	if !t.IsZero() {
+		b.deadlineTimer = time.AfterFunc(time.Until(t), func() {
+			b.mutex.Lock()
+			b.cond.Broadcast()
+			b.mutex.Unlock()
+		})
+	}
+
+	// blocked readers check the new deadline
+	b.cond.Broadcast()
+}
+
+// Next sequence number to be delivered (all packets before it are received)
+func (b *RecvBuffer) GetExpectedSeqNumber() uint32 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.expectedSeqNumber
+}
+
+// Current and maximum number of packets in reorderBuffer, and the number of packets received out of order
+func (b *RecvBuffer) GetReorderStats() (int, int, uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.reorderBuffer), b.maxReorderLen, b.reorderedPackets
 }
 
 func (b *RecvBuffer) IsEmpty() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 	return (len(b.readBuffer) == 0)
 }
 
 func (b *RecvBuffer) GetLength() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 	return len(b.readBuffer)
 }
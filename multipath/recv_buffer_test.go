@@ -0,0 +1,180 @@
+package multipath
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+const WRAP_START_SEQ = 0xFFFFFFFE // 0xFFFFFFFE, 0xFFFFFFFF, 0x00000000, 0x00000001, ...
+
+// Receive buffer expecting WRAP_START_SEQ as the next sequence number
+func createWrapRecvBuffer() *RecvBuffer {
+	b := CreateRecvBuffer()
+	b.readSeqNumber = WRAP_START_SEQ
+	b.recvSeqNumber = WRAP_START_SEQ
+	b.expectedSeqNumber = WRAP_START_SEQ
+	return b
+}
+
+func pushPayloads(b *RecvBuffer, seqs []uint32) {
+	for _, seq := range seqs {
+		b.PushPacket(CreateDataPacket(1, 0, seq, []byte{byte(seq)}))
+	}
+}
+
+func readAll(t *testing.T, b *RecvBuffer, length int) []byte {
+	buf := make([]byte, length)
+	_, err := io.ReadFull(b, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestSeqBefore(t *testing.T) {
+	tests := []struct {
+		a, b     uint32
+		expected bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{5, 5, false},
+		{0xFFFFFFFE, 0xFFFFFFFF, true},
+		{0xFFFFFFFF, 0x00000000, true},
+		{0xFFFFFFFE, 0x00000001, true},
+		{0x00000001, 0xFFFFFFFE, false},
+		{0x00000000, 0xFFFFFFFF, false},
+	}
+
+	for _, test := range tests {
+		if seqBefore(test.a, test.b) != test.expected {
+			t.Errorf("seqBefore(0x%x, 0x%x) != %t", test.a, test.b, test.expected)
+		}
+	}
+}
+
+func TestRecvBufferInOrderAcrossWrap(t *testing.T) {
+	b := createWrapRecvBuffer()
+	seqs := []uint32{0xFFFFFFFE, 0xFFFFFFFF, 0x00000000, 0x00000001}
+	pushPayloads(b, seqs)
+
+	if b.GetExpectedSeqNumber() != 0x00000002 {
+		t.Fatalf("expected sequence number 0x%x", b.GetExpectedSeqNumber())
+	}
+	if got := readAll(t, b, len(seqs)); !bytes.Equal(got, []byte{0xFE, 0xFF, 0x00, 0x01}) {
+		t.Fatalf("read %x", got)
+	}
+}
+
+func TestRecvBufferOutOfOrderAcrossWrap(t *testing.T) {
+	b := createWrapRecvBuffer()
+
+	// Packets after the wrap wait in the reorder buffer (they are not regarded as duplicates)
+	pushPayloads(b, []uint32{0x00000001, 0x00000000, 0xFFFFFFFF})
+	if b.GetLength() != 0 {
+		t.Fatalf("%d bytes are readable before 0x%x", b.GetLength(), WRAP_START_SEQ)
+	}
+	if reorderLen, _, _ := b.GetReorderStats(); reorderLen != 3 {
+		t.Fatalf("%d packets in reorder buffer", reorderLen)
+	}
+
+	pushPayloads(b, []uint32{0xFFFFFFFE})
+	if b.GetExpectedSeqNumber() != 0x00000002 {
+		t.Fatalf("expected sequence number 0x%x", b.GetExpectedSeqNumber())
+	}
+	if got := readAll(t, b, 4); !bytes.Equal(got, []byte{0xFE, 0xFF, 0x00, 0x01}) {
+		t.Fatalf("read %x", got)
+	}
+}
+
+func TestRecvBufferDuplicateAfterWrap(t *testing.T) {
+	b := createWrapRecvBuffer()
+	pushPayloads(b, []uint32{0xFFFFFFFE, 0xFFFFFFFF, 0x00000000})
+
+	// Reinjected packets which are already delivered are dropped
+	pushPayloads(b, []uint32{0xFFFFFFFF, 0x00000000})
+	if b.GetLength() != 3 {
+		t.Fatalf("%d bytes are readable after duplicates", b.GetLength())
+	}
+	if reorderLen, _, _ := b.GetReorderStats(); reorderLen != 0 {
+		t.Fatalf("%d duplicates in reorder buffer", reorderLen)
+	}
+	if b.GetExpectedSeqNumber() != 0x00000001 {
+		t.Fatalf("expected sequence number 0x%x", b.GetExpectedSeqNumber())
+	}
+}
+
+func TestRecvBufferCloseAfterWrappedFinalSeq(t *testing.T) {
+	b := createWrapRecvBuffer()
+	pushPayloads(b, []uint32{0xFFFFFFFE, 0x00000000})
+
+	// Goodbye with the final sequence number after the wrap arrives before the last packets
+	b.CloseAfter(0x00000001)
+	select {
+	case <-b.Closed():
+		t.Fatal("closed before packets of the final sequence number are received")
+	default:
+	}
+
+	pushPayloads(b, []uint32{0xFFFFFFFF})
+	select {
+	case <-b.Closed():
+	default:
+		t.Fatal("not closed after all packets are received")
+	}
+
+	if got := readAll(t, b, 3); !bytes.Equal(got, []byte{0xFE, 0xFF, 0x00}) {
+		t.Fatalf("read %x", got)
+	}
+	if _, err := b.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read after close: %v", err)
+	}
+}
+
+func TestSendBufferAcrossWrap(t *testing.T) {
+	b := CreateSendBuffer()
+	for _, seq := range []uint32{0x00000001, 0xFFFFFFFE, 0x00000000, 0xFFFFFFFF, 0x00000002} {
+		b.PushPacket(CreateDataPacket(1, 0, seq, []byte{byte(seq)}), 0)
+	}
+
+	// Reinjection order follows sequence numbers across the wrap
+	expected := []uint32{0xFFFFFFFE, 0xFFFFFFFF, 0x00000000, 0x00000001, 0x00000002}
+	packets := b.GetPackets(0)
+	if len(packets) != len(expected) {
+		t.Fatalf("%d packets", len(packets))
+	}
+	for i, packet := range packets {
+		if packet.SeqNumber != expected[i] {
+			t.Fatalf("packet %d has sequence number 0x%x, expected 0x%x", i, packet.SeqNumber, expected[i])
+		}
+	}
+
+	// Packets before 0x00000001 are delivered, including the ones before the wrap
+	if count := b.AckPacketsBefore(0x00000001); count != 3 {
+		t.Fatalf("%d packets are removed", count)
+	}
+	packets = b.GetPackets(0)
+	if len(packets) != 2 || packets[0].SeqNumber != 0x00000001 || packets[1].SeqNumber != 0x00000002 {
+		t.Fatalf("%d packets remain", len(packets))
+	}
+}
+
+// Path IDs are not reused, so they exceed the byte of DataPacket.PathID after many reconnects
+func TestSendBufferPathIDOverByte(t *testing.T) {
+	b := CreateSendBuffer()
+	b.PushPacket(CreateDataPacket(1, 300, 1, []byte{1}), 300)
+	b.PushPacket(CreateDataPacket(1, 44, 2, []byte{2}), 44)
+
+	packets := b.GetPackets(300)
+	if len(packets) != 1 || packets[0].SeqNumber != 1 {
+		t.Fatalf("%d packets of path 300", len(packets))
+	}
+	if packets = b.GetPackets(44); len(packets) != 1 || packets[0].SeqNumber != 2 {
+		t.Fatalf("%d packets of path 44", len(packets))
+	}
+
+	if _, pathID, _ := b.AckPacket(1); pathID != 300 {
+		t.Fatalf("packet 1 is sent through path %d", pathID)
+	}
+}
@@ -0,0 +1,53 @@
+package multipath
+
+import (
+	"time"
+)
+
+// Redundant scheduler (SCHED_REDUNDANT)
+// Every packet is sent through all available paths for the lowest latency and loss resilience.
+// Duplicate packets are dropped by RecvBuffer of the receiver.
+type RedundantScheduler struct {
+	schedulerBase
+}
+
+func CreateRedundantScheduler() *RedundantScheduler {
+	c := RedundantScheduler{}
+
+	return &c
+}
+
+func (c *RedundantScheduler) SetNumPath(numPath int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setNumPath(numPath)
+}
+
+func (c *RedundantScheduler) SetPathAvailable(pathID int, available bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setPathAvailable(pathID, available)
+}
+
+func (c *RedundantScheduler) UpdatePathCondition(pathID int, rtt time.Duration, ackedBytes uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.updateRTT(pathID, rtt)
+}
+
+func (c *RedundantScheduler) Scheduling(payloadSize uint32) []int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pathIDs := make([]int, 0, c.numPath)
+	for i := 0; i < c.numPath; i++ {
+		if c.pathAvailable[i] {
+			pathIDs = append(pathIDs, i)
+		}
+	}
+
+	return pathIDs
+}
@@ -0,0 +1,58 @@
+package multipath
+
+import (
+	"bytes"
+)
+
+const REMOVE_PATH_PACKET_HEADER_LEN = 7 // header length of remove path packet
+
+// Announce that the path where the packet is sent is removed from the session
+type RemovePathPacket struct {
+	Type      byte
+	Length    uint16
+	SessionID uint32
+}
+
+func CreateRemovePathPacket(sessionID uint32) *RemovePathPacket {
+	packet := RemovePathPacket{}
+	packet.Type = REMOVE_PATH_PACKET
+	packet.Length = REMOVE_PATH_PACKET_HEADER_LEN
+	packet.SessionID = sessionID
+	return &packet
+}
+
+func ParseRemovePathPacket(r *bytes.Reader) (*RemovePathPacket, error) {
+	packetType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packetLegnth, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := &RemovePathPacket{}
+	packet.Type = packetType
+	packet.Length = packetLegnth
+	packet.SessionID = sessionID
+
+	return packet, nil
+}
+
+func (p *RemovePathPacket) GetType() byte {
+	return p.Type
+}
+
+// Writes Remove Path Packet
+func (p *RemovePathPacket) Write(b *bytes.Buffer) error {
+	b.WriteByte(p.Type)
+	WriteUint16(b, uint16(p.Length))
+	WriteUint32(b, uint32(p.SessionID))
+	return nil
+}
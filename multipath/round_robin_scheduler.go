@@ -0,0 +1,52 @@
+package multipath
+
+import (
+	"time"
+)
+
+// Round robin scheduler (SCHED_ROUND_ROBIN)
+// Packets are sent through available paths in turn.
+type RoundRobinScheduler struct {
+	schedulerBase
+	currentPath int
+}
+
+func CreateRoundRobinScheduler() *RoundRobinScheduler {
+	c := RoundRobinScheduler{}
+
+	return &c
+}
+
+func (c *RoundRobinScheduler) SetNumPath(numPath int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setNumPath(numPath)
+}
+
+func (c *RoundRobinScheduler) SetPathAvailable(pathID int, available bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setPathAvailable(pathID, available)
+}
+
+func (c *RoundRobinScheduler) UpdatePathCondition(pathID int, rtt time.Duration, ackedBytes uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.updateRTT(pathID, rtt)
+}
+
+func (c *RoundRobinScheduler) Scheduling(payloadSize uint32) []int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	selectedPath := c.nextAvailablePath(c.currentPath)
+	if selectedPath < 0 {
+		return nil
+	}
+	c.currentPath = selectedPath
+
+	return []int{selectedPath}
+}
@@ -0,0 +1,96 @@
+package multipath
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// pathID is kept apart from DataPacket.PathID, which is truncated to a byte on the wire
+// (path IDs are not reused, so they may exceed 255)
+type sentPacket struct {
+	packet   *DataPacket
+	pathID   int
+	sentTime time.Time
+}
+
+// Keeps data packets sent but not yet acknowledged by the peer
+type SendBuffer struct {
+	mutex         sync.Mutex
+	unackedBuffer map[uint32]*sentPacket
+}
+
+func CreateSendBuffer() *SendBuffer {
+	b := SendBuffer{
+		unackedBuffer: make(map[uint32]*sentPacket),
+	}
+
+	return &b
+}
+
+// Push a packet sent through the path into unackedBuffer
+// If the packet is reinjected, the previous entry is replaced (path is updated)
+func (b *SendBuffer) PushPacket(packet *DataPacket, pathID int) {
+	b.mutex.Lock()
+	b.unackedBuffer[packet.SeqNumber] = &sentPacket{packet: packet, pathID: pathID, sentTime: time.Now()}
+	b.mutex.Unlock()
+}
+
+// Remove an acknowledged packet from unackedBuffer
+// Returns the packet, the path where it was sent and the time elapsed since it was sent (RTT sample)
+func (b *SendBuffer) AckPacket(seq uint32) (*DataPacket, int, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sent, exists := b.unackedBuffer[seq]
+	if !exists {
+		// already acknowledged (e.g. the packet was reinjected and received twice)
+		return nil, -1, 0
+	}
+	delete(b.unackedBuffer, seq)
+
+	return sent.packet, sent.pathID, time.Since(sent.sentTime)
+}
+
+// Remove packets before seq, which are delivered to the peer (e.g. their ACKs are lost with failed paths)
+// Returns the number of removed packets
+func (b *SendBuffer) AckPacketsBefore(seq uint32) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	count := 0
+	for packetSeq := range b.unackedBuffer {
+		if seqBefore(packetSeq, seq) {
+			delete(b.unackedBuffer, packetSeq)
+			count++
+		}
+	}
+
+	return count
+}
+
+// Get unacknowledged packets sent through the path (in order of sequence number)
+func (b *SendBuffer) GetPackets(pathID int) []*DataPacket {
+	b.mutex.Lock()
+
+	packets := make([]*DataPacket, 0)
+	for _, sent := range b.unackedBuffer {
+		if sent.pathID == pathID {
+			packets = append(packets, sent.packet)
+		}
+	}
+
+	b.mutex.Unlock()
+
+	sort.Slice(packets, func(i, j int) bool {
+		return seqBefore(packets[i].SeqNumber, packets[j].SeqNumber)
+	})
+
+	return packets
+}
+
+func (b *SendBuffer) GetLength() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.unackedBuffer)
+}
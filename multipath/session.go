@@ -1,353 +1,1807 @@
 package multipath
 
 import (
-	"bytes"
 	"context"
+	"crypto/hmac"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	quic "github.com/lucas-clemente/quic-go"
 )
 
+const (
+	PATH_ACTIVE  = 1 // Path is used for transmission
+	PATH_FAILED  = 2 // QUIC connection of path is broken
+	PATH_REMOVED = 3 // Path is removed by RemovePath() of either side
+	PATH_DOWN    = 4 // Nothing is received through the path (see Config.PingIntervalMs)
+)
+
+const PATH_REMOVE_TIMEOUT = 3 * time.Second // time to wait for the peer to close a removed path
+
+const CLOSE_TIMEOUT = 3 * time.Second // time for Close() to wait for Goodbye ACK
+
+const IDLE_CHECK_INTERVAL = 1 * time.Second // interval of checking Config.IdleTimeoutMs
+
+const HAPPY_EYEBALLS_DELAY = 250 * time.Millisecond // delay before trying the next address of an interface (RFC 8305)
+
+const RECONNECT_MAX_INTERVAL = 60 * time.Second // upper limit of the reconnect interval doubled after each failed attempt
+
+// Path IDs are not reused after a path is failed or removed,
+// so the per-path slices of a session only grow
+// (a lost path is reconnected as a new path, and the connection and stream of a closed path are released)
+
+// Path up/down event (see SetPathEventHandler())
+type PathEvent struct {
+	PathID int
+	Addr   string // remote address of the path
+	Up     bool
+	Err    error // cause of down event (nil if the path is removed)
+}
+
 // For multipath session
 type Session struct {
-	SessionID         uint32
-	numPath           int
-	streamList        []quic.Stream
-	listenAddrList    []string
-	connectedAddrList []string
-	sequenceNumber    uint32
-	sentBytes         []uint32
-	recvBytes         []uint32
-	scheduler         *SessionScheduler
-	recvBuffer        *RecvBuffer
-	goodbye           bool
-}
-
-func CreateSession(sessionID uint32, addrList []string) *Session {
+	SessionID          uint32
+	token              [RESUMPTION_TOKEN_LEN]byte // issued by acceptor (see HelloAckPacket)
+	joinKey            []byte                     // authenticates additional paths (see deriveJoinKey())
+	mutex              sync.Mutex
+	config             *Config
+	logger             logger
+	tlsConf            *tls.Config         // base TLS configuration of new paths (see Config.loadTLSConfig())
+	serverName         string              // name verified in the certificate of the acceptor on all paths
+	peerCertificates   []*x509.Certificate // verified certificate chain of the peer (first path)
+	version            byte                // negotiated protocol version (0 before Hello ACK)
+	capabilities       uint32              // capabilities supported by both sides (CAP_*)
+	payloadSize        int                 // payload size of data packets (limited by the peer)
+	peerPacketSize     int                 // maximum packet size which the peer can receive (0 if unknown)
+	numPath            int
+	initiator          bool // session is connected by Connect() (only initiator can connect a new path)
+	connectionList     []quic.Connection
+	streamList         []quic.Stream
+	streamMutexList    []*sync.Mutex
+	pathStatusList     []int
+	startedList        []bool // receiver of the path is started (handshake is completed)
+	lastRecvTimeList   []time.Time
+	pingSeqList        []uint32 // sequence number of the last ping
+	pingTimeList       []time.Time
+	rttList            []time.Duration // smoothed RTT measured by ping
+	pathEventHandler   func(event PathEvent)
+	pathChan           chan struct{} // closed and renewed when a path is up or the session is closed
+	listenAddrList     []string
+	nicInfoList        []NicInfo // NIC information of listen addresses advertised to the peer
+	localAddrList      []string
+	localAddrIndex     int // next local address of Config.LocalAddrs for a new path
+	connectedAddrList  []string
+	advertisedAddrList []string   // addresses advertised by the peer (by the last Hello ACK and Add Path packets)
+	writeMutex         sync.Mutex // serializes Write() calls of different go routines
+	readMutex          sync.Mutex // serializes ReadMessage() calls of different go routines
+	sequenceNumber     uint32
+	sentBytes          []uint64
+	recvBytes          []uint64
+	sentPackets        []uint64 // data packets (including reinjected ones)
+	recvPackets        []uint64
+	reinjectedPackets  []uint64 // data packets reinjected from the path into another path
+	startTime          time.Time
+	handshakeFailures  uint64 // paths which failed to connect
+	pathUps            uint64 // path up events (see PathEvent)
+	pathDowns          uint64
+	scheduler          SessionScheduler
+	sendBuffer         *SendBuffer
+	recvBuffer         *RecvBuffer
+	goodbye            bool // goodbye is received from peer
+	goodbyeAckChan     chan struct{}
+	goodbyeAcked       bool
+	closing            bool   // Close() is waiting for Goodbye ACK (Write() is refused)
+	closed             bool   // session is closed by Close() or idle timeout
+	closeHandler       func() // called when the session is closed (SessionManager removes the session)
+	lastRecvTime       time.Time
+	writeDeadline      time.Time
+	ctx                context.Context // done when the session is closed (bounds the setup of additional paths)
+	cancel             context.CancelFunc
+}
+
+// Session can be used as net.Conn (e.g. transport of gRPC)
+var _ net.Conn = (*Session)(nil)
+
+// Addresses of all paths of a session
+type SessionAddr struct {
+	AddrList []string
+}
+
+func (a *SessionAddr) Network() string {
+	return "multipath"
+}
+
+func (a *SessionAddr) String() string {
+	return strings.Join(a.AddrList, ",")
+}
+
+func CreateSession(sessionID uint32, config *Config, scheduler SessionScheduler) *Session {
+	if scheduler == nil {
+		scheduler = config.CreateScheduler()
+	}
+
 	s := Session{
-		SessionID:         sessionID,
-		numPath:           0,
-		streamList:        make([]quic.Stream, 0),
-		listenAddrList:    addrList,
-		connectedAddrList: make([]string, 0),
-		sequenceNumber:    0,
-		sentBytes:         make([]uint32, 0),
-		recvBytes:         make([]uint32, 0),
-		scheduler:         CreateSessionScheduler(SCHED_USER_WRR),
-		recvBuffer:        CreateRecvBuffer(),
-		goodbye:           false,
+		SessionID:          sessionID,
+		config:             config,
+		logger:             config.logger(),
+		numPath:            0,
+		connectionList:     make([]quic.Connection, 0),
+		streamList:         make([]quic.Stream, 0),
+		streamMutexList:    make([]*sync.Mutex, 0),
+		pathStatusList:     make([]int, 0),
+		pathChan:           make(chan struct{}),
+		listenAddrList:     config.ListenAddrs,
+		nicInfoList:        make([]NicInfo, 0),
+		localAddrList:      make([]string, 0),
+		connectedAddrList:  make([]string, 0),
+		advertisedAddrList: make([]string, 0),
+		sequenceNumber:     0,
+		sentBytes:          make([]uint64, 0),
+		recvBytes:          make([]uint64, 0),
+		sentPackets:        make([]uint64, 0),
+		recvPackets:        make([]uint64, 0),
+		reinjectedPackets:  make([]uint64, 0),
+		payloadSize:        config.PayloadSize,
+		startTime:          time.Now(),
+		scheduler:          scheduler,
+		sendBuffer:         CreateSendBuffer(),
+		recvBuffer:         CreateRecvBuffer(),
+		goodbye:            false,
+		goodbyeAckChan:     make(chan struct{}),
+		lastRecvTime:       time.Now(),
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.recvBuffer.logger = s.logger
+	setSchedulerLogger(scheduler, s.logger)
+
+	// Each configured address is regarded as a distinct interface
+	// (SessionManager sets NIC information of its listeners)
+	for i, addr := range config.ListenAddrs {
+		nicInfo, err := CreateNicInfo(NIC_TYPE_UNKNOWN, uint16(i+1), addr)
+		if err == nil {
+			s.nicInfoList = append(s.nicInfoList, nicInfo)
+		}
+	}
+
+	// Close the session if it is idle
+	if config.IdleTimeoutMs > 0 {
+		go s.monitorIdle()
+	}
+
+	return &s
+}
+
+// Connect the first path to the address (see AddPath() for additional paths)
+func (s *Session) Connect(addr string) error {
+	return s.connectContext(context.Background(), addr)
+}
+
+// ctx bounds the handshake of the first path (QUIC handshake, stream and Hello ACK)
+// Additional paths are connected after the first one regardless of ctx, until the session is closed
+func (s *Session) connectContext(ctx context.Context, addr string) error {
+	// Additional paths to advertised IPs verify the same name as the first path
+	serverName, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		serverName = addr
+	}
+
+	s.mutex.Lock()
+	s.initiator = true
+	s.serverName = serverName
+	s.mutex.Unlock()
+
+	return s.connect(ctx, "", addr)
+}
+
+// Connect a new path from localAddr ("" for the next configured local address) to addr
+func (s *Session) connect(ctx context.Context, localAddr string, addr string) error {
+	quicSess, err := s.dial(ctx, localAddr, addr)
+	if err != nil {
+		return err
+	}
+
+	return s.setupPath(ctx, quicSess, addr)
+}
+
+// Connect one of the addresses of a network interface (Happy Eyeballs, RFC 8305)
+// IPv6 is tried first, and the next address is tried in parallel
+// if the previous one is not connected within HAPPY_EYEBALLS_DELAY
+func (s *Session) connectNic(ctx context.Context, nicInfos []NicInfo) error {
+	// IPv6 and IPv4 addresses in turn
+	addrList := make([]string, 0, len(nicInfos))
+	ipv6List, ipv4List := make([]string, 0), make([]string, 0)
+	for _, nicInfo := range nicInfos {
+		if nicInfo.Family == NIC_FAMILY_IPV6 {
+			ipv6List = append(ipv6List, nicInfo.String())
+		} else {
+			ipv4List = append(ipv4List, nicInfo.String())
+		}
+	}
+	for i := 0; i < len(ipv6List) || i < len(ipv4List); i++ {
+		if i < len(ipv6List) {
+			addrList = append(addrList, ipv6List[i])
+		}
+		if i < len(ipv4List) {
+			addrList = append(addrList, ipv4List[i])
+		}
+	}
+
+	type dialResult struct {
+		quicSess quic.Connection
+		addr     string
+		err      error
+	}
+
+	dialCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(addrList))
+	next, pending := 0, 0
+	var lastErr error
+
+	for next < len(addrList) || pending > 0 {
+		if next < len(addrList) {
+			addr := addrList[next]
+			next++
+			pending++
+			go func() {
+				quicSess, err := s.dial(dialCtx, "", addr)
+				results <- dialResult{quicSess: quicSess, addr: addr, err: err}
+			}()
+		}
+
+		var timer <-chan time.Time
+		if next < len(addrList) {
+			timer = time.After(HAPPY_EYEBALLS_DELAY)
+		}
+
+		select {
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				// Try the next address immediately
+				lastErr = result.err
+				continue
+			}
+
+			// Connections of the other attempts are not used
+			cancel()
+			go func(pending int) {
+				for i := 0; i < pending; i++ {
+					if other := <-results; other.err == nil {
+						other.quicSess.CloseWithError(0, "")
+					}
+				}
+			}(pending)
+
+			s.logger.Log("Session.connectNic(): %s is selected among %v", result.addr, addrList)
+
+			return s.setupPath(ctx, result.quicSess, result.addr)
+
+		case <-timer:
+		}
+	}
+
+	return lastErr
+}
+
+// QUIC handshake of a new path
+func (s *Session) dial(ctx context.Context, localAddr string, addr string) (quic.Connection, error) {
+	// Connect to Master listener
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, &PathError{Op: "connect", PathID: -1, Addr: addr, Err: err}
+	}
+
+	// Bind to a distinct local address, so that paths traverse distinct interfaces
+	device := ""
+	if localAddr == "" {
+		localAddr, device = s.selectLocalAddr(udpAddr)
+	}
+
+	udpConn, err := listenUDP(localAddr, device)
+	if err != nil {
+		return nil, &PathError{Op: "connect", PathID: -1, Addr: addr, Err: err}
+	}
+
+	// TLS configuration
+	tlsConf, err := s.clientTLSConfig()
+	if err != nil {
+		udpConn.Close()
+		return nil, &PathError{Op: "connect", PathID: -1, Addr: addr, Err: err}
+	}
+
+	// QUIC Dial
+	quicSess, err := quic.DialContext(ctx, udpConn, udpAddr, addr, tlsConf, &quic.Config{Tracer: quicTracer})
+	if err != nil {
+		udpConn.Close()
+
+		// Cancelled attempts of Happy Eyeballs are not failures
+		if ctx.Err() == nil {
+			s.countHandshakeFailure()
+		}
+		return nil, &PathError{Op: "connect", PathID: -1, Addr: addr, Err: err}
+	}
+
+	// UDP socket passed to quic.Dial() is not closed by quic-go
+	go func() {
+		<-quicSess.Context().Done()
+		udpConn.Close()
+	}()
+
+	return quicSess, nil
+}
+
+// TLS configuration of a new path (Config is loaded if the session is not created by SessionManager)
+func (s *Session) clientTLSConfig() (*tls.Config, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.tlsConf == nil {
+		tlsConf, err := s.config.loadTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConf = tlsConf
+	}
+
+	return clientTLSConfig(s.tlsConf, s.serverName), nil
+}
+
+// Set the protocol negotiated by Hello and Hello ACK
+// Additional paths should not change the protocol of the first path
+func (s *Session) setProtocol(version byte, capabilities uint32, peerMaxPayloadSize uint16) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.version != 0 && (version != s.version || capabilities != s.capabilities) {
+		return fmt.Errorf("%w: version %d and capabilities 0x%x differ from the first path (version %d, capabilities 0x%x)",
+			ErrIncompatiblePeer, version, capabilities, s.version, s.capabilities)
+	}
+
+	s.version = version
+	s.capabilities = capabilities
+
+	// Data packets should fit in the receive buffer of the peer
+	if peerMaxPayloadSize > 0 && int(peerMaxPayloadSize) < s.payloadSize {
+		s.payloadSize = int(peerMaxPayloadSize)
+	}
+	if peerMaxPayloadSize > 0 {
+		s.peerPacketSize = int(peerMaxPayloadSize) + DATA_PACKET_HEADER_LEN
+	}
+
+	return nil
+}
+
+// Negotiated protocol version (0 before handshake)
+func (s *Session) ProtocolVersion() byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.version
+}
+
+// Capabilities supported by both sides (CAP_*)
+func (s *Session) Capabilities() uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.capabilities
+}
+
+// Verified certificate chain of the peer (leaf certificate first)
+// nil if the peer is not verified (e.g. no CA is configured)
+func (s *Session) PeerCertificates() []*x509.Certificate {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.peerCertificates
+}
+
+// All paths should be connected to the peer of the first path
+func (s *Session) checkPeer(conn quic.Connection) error {
+	chain := verifiedChain(conn)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.numPath == 0 {
+		s.peerCertificates = chain
+		return nil
 	}
 
-	return &s
+	if !samePeer(s.peerCertificates, chain) {
+		return ErrPeerMismatch
+	}
+
+	return nil
+}
+
+// Open a stream of a new QUIC connection and add it to the session as a path
+// The handshake is aborted when ctx is done
+func (s *Session) setupPath(ctx context.Context, quicSess quic.Connection, addr string) error {
+	err := s.checkPeer(quicSess)
+	if err != nil {
+		s.countHandshakeFailure()
+		quicSess.CloseWithError(QUIC_ERROR_HANDSHAKE_FAILED, err.Error())
+		return &PathError{Op: "connect", PathID: -1, Addr: addr, Err: err}
+	}
+
+	// QUIC OpenStreamSync
+	quicStream, err := quicSess.OpenStreamSync(ctx)
+	if err != nil {
+		s.countHandshakeFailure()
+		quicSess.CloseWithError(QUIC_ERROR_HANDSHAKE_FAILED, err.Error())
+		return &PathError{Op: "connect", PathID: -1, Addr: addr, Err: err}
+	}
+
+	s.logger.Log("Session.Connect(): Connect to %s (%s) from %s", addr, quicSess.RemoteAddr().String(), quicSess.LocalAddr().String())
+
+	// Add a created session into session map
+	pathID := s.AddStream(quicSess, quicStream)
+
+	// Send Hello Packet
+	s.sendHelloPacket(pathID)
+
+	// Receive hello ack packet
+	err = s.receiveHelloAckPacketContext(ctx, pathID)
+	if err != nil {
+		err = &PathError{Op: "connect", PathID: pathID, Addr: addr, Err: err}
+		s.logger.Log("Session.Connect(): %v", err)
+		s.countHandshakeFailure()
+		s.handlePathFailure(pathID, err)
+		quicSess.CloseWithError(QUIC_ERROR_HANDSHAKE_FAILED, err.Error())
+		return err
+	}
+
+	// Start receiver
+	s.StartReceiver(pathID)
+
+	return nil
+}
+
+func (s *Session) countHandshakeFailure() {
+	s.mutex.Lock()
+	s.handshakeFailures++
+	s.mutex.Unlock()
+}
+
+func (s *Session) AddStream(conn quic.Connection, stream quic.Stream) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.connectionList = append(s.connectionList, conn)
+	s.streamList = append(s.streamList, stream)
+	s.streamMutexList = append(s.streamMutexList, &sync.Mutex{})
+	s.pathStatusList = append(s.pathStatusList, PATH_ACTIVE)
+	s.localAddrList = append(s.localAddrList, conn.LocalAddr().String())
+	s.connectedAddrList = append(s.connectedAddrList, conn.RemoteAddr().String())
+	s.startedList = append(s.startedList, false)
+	s.lastRecvTimeList = append(s.lastRecvTimeList, time.Now())
+	s.pingSeqList = append(s.pingSeqList, 0)
+	s.pingTimeList = append(s.pingTimeList, time.Time{})
+	s.rttList = append(s.rttList, 0)
+	s.numPath++
+	s.sentBytes = append(s.sentBytes, 0)
+	s.recvBytes = append(s.recvBytes, 0)
+	s.sentPackets = append(s.sentPackets, 0)
+	s.recvPackets = append(s.recvPackets, 0)
+	s.reinjectedPackets = append(s.reinjectedPackets, 0)
+
+	return (s.numPath - 1)
+}
+
+// Add a new path to the session
+// Initiator connects to remoteAddr from localAddr ("" for the next address of Config.LocalAddrs).
+// Acceptor cannot connect to the initiator, so it advertises localAddr (one of the listen addresses)
+// and the initiator connects to it (remoteAddr is not used).
+func (s *Session) AddPath(localAddr string, remoteAddr string) error {
+	s.mutex.Lock()
+	initiator, goodbye := s.initiator, s.goodbye
+	s.mutex.Unlock()
+
+	if goodbye || s.isClosed() {
+		return net.ErrClosed
+	}
+
+	if initiator {
+		if s.isConnected(remoteAddr) {
+			return &PathError{Op: "add", PathID: -1, Addr: remoteAddr, Err: fmt.Errorf("%w: already connected", ErrInvalidAddress)}
+		}
+		return s.connect(s.ctx, localAddr, remoteAddr)
+	}
+
+	s.mutex.Lock()
+	listened := false
+	for _, addr := range s.listenAddrList {
+		if addr == localAddr {
+			listened = true
+		}
+	}
+	s.mutex.Unlock()
+
+	if !listened {
+		return &PathError{Op: "add", PathID: -1, Addr: localAddr, Err: fmt.Errorf("%w: not a listen address", ErrInvalidAddress)}
+	}
+
+	return s.sendAddPathPacket(localAddr)
+}
+
+// Remove a path from the session
+// Unacknowledged packets of the path are reinjected into the other paths
+func (s *Session) RemovePath(pathID int) error {
+	s.mutex.Lock()
+	if pathID < 0 || pathID >= s.numPath || s.pathStatusList[pathID] != PATH_ACTIVE {
+		s.mutex.Unlock()
+		return &PathError{Op: "remove", PathID: pathID, Err: ErrPathClosed}
+	}
+
+	numActivePath := 0
+	for _, status := range s.pathStatusList {
+		if status == PATH_ACTIVE {
+			numActivePath++
+		}
+	}
+	addr := s.connectedAddrList[pathID]
+	s.mutex.Unlock()
+
+	if numActivePath == 1 {
+		return &PathError{Op: "remove", PathID: pathID, Addr: addr, Err: ErrLastPath}
+	}
+
+	// Announce through the path itself, so the peer knows which path is removed
+	s.sendRemovePathPacket(pathID)
+
+	s.removePath(pathID)
+
+	// QUIC connection is closed when the peer closes its side of the path (or after timeout)
+	time.AfterFunc(PATH_REMOVE_TIMEOUT, func() {
+		s.closeConnection(pathID)
+	})
+
+	return nil
+}
+
+// IDs of active paths
+func (s *Session) PathIDs() []int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pathIDs := make([]int, 0, s.numPath)
+	for pathID, status := range s.pathStatusList {
+		if status == PATH_ACTIVE {
+			pathIDs = append(pathIDs, pathID)
+		}
+	}
+
+	return pathIDs
+}
+
+// Addresses advertised by the peer
+// If Config.AutoConnect is false, the initiator connects them by AddPath()
+func (s *Session) AdvertisedAddrs() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	addrList := make([]string, len(s.advertisedAddrList))
+	copy(addrList, s.advertisedAddrList)
+
+	return addrList
+}
+
+// Select the local address (and its device) of a new path to remoteAddr
+// Configured local addresses are used in turn, skipping ones of the other IP family
+// and loopback addresses for a remote address which is not loopback
+func (s *Session) selectLocalAddr(remoteAddr *net.UDPAddr) (string, string) {
+	localAddrList := s.config.LocalAddrs
+	deviceList := s.config.BindDevices
+	if len(localAddrList) == 0 {
+		// IPs of listen addresses with any port
+		localAddrList = make([]string, 0)
+		deviceList = nil
+		for _, addr := range s.config.ListenAddrs {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				localAddrList = append(localAddrList, host)
+			}
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := 0; i < len(localAddrList); i++ {
+		index := (s.localAddrIndex + i) % len(localAddrList)
+
+		host, port := localAddrList[index], "0"
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			host, port = h, p
+		}
+
+		ip := net.ParseIP(host)
+		if ip != nil {
+			if (ip.To4() != nil) != (remoteAddr.IP.To4() != nil) {
+				continue
+			}
+			if ip.IsLoopback() && !remoteAddr.IP.IsLoopback() {
+				continue
+			}
+		}
+
+		s.localAddrIndex = index + 1
+
+		device := ""
+		if index < len(deviceList) {
+			device = deviceList[index]
+		}
+
+		return net.JoinHostPort(host, port), device
+	}
+
+	// Any address (interface is selected by the routing table)
+	return ":0", ""
+}
+
+// Set listen addresses advertised to the peer (e.g. when network interfaces are changed)
+func (s *Session) setNicInfos(nicInfos []NicInfo) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nicInfoList = nicInfos
+	s.listenAddrList = make([]string, len(nicInfos))
+	for i := range nicInfos {
+		s.listenAddrList[i] = nicInfos[i].String()
+	}
+}
+
+// Remove active paths whose local address is removed
+func (s *Session) removePathsFrom(localAddr string) {
+	s.mutex.Lock()
+	pathIDs := make([]int, 0)
+	for pathID, addr := range s.localAddrList {
+		if addr == localAddr && s.pathStatusList[pathID] == PATH_ACTIVE {
+			pathIDs = append(pathIDs, pathID)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, pathID := range pathIDs {
+		err := s.RemovePath(pathID)
+		if err != nil {
+			s.logger.Log("Session.removePathsFrom(): %v", err)
+		}
+	}
+}
+
+// Whether an active path is connected to the address
+func (s *Session) isConnected(addr string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for pathID, conAddr := range s.connectedAddrList {
+		if conAddr == addr && s.pathStatusList[pathID] == PATH_ACTIVE {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close the QUIC connection of a failed or removed path, and release the connection and its stream
+func (s *Session) closeConnection(pathID int) {
+	s.mutex.Lock()
+	conn := s.connectionList[pathID]
+	s.connectionList[pathID] = nil
+	s.streamList[pathID] = nil
+	s.mutex.Unlock()
+
+	if conn != nil {
+		conn.CloseWithError(0, "")
+	}
+}
+
+// Replace scheduler (e.g. by SessionManager.Accept())
+func (s *Session) SetScheduler(scheduler SessionScheduler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// New scheduler takes over the paths of the old one
+	setSchedulerLogger(scheduler, s.logger)
+	scheduler.SetNumPath(s.numPath)
+	for pathID, status := range s.pathStatusList {
+		if status != PATH_ACTIVE {
+			scheduler.SetPathAvailable(pathID, false)
+		}
+	}
+
+	s.scheduler = scheduler
+}
+
+func (s *Session) getScheduler() SessionScheduler {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.scheduler
+}
+
+// Set numPath for scheduler -> scheduler begins to consider an added path
+func (s *Session) updateSchedulerPaths() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.scheduler.SetNumPath(s.numPath)
+}
+
+// Get stream and its status of the path
+func (s *Session) getStream(pathID int) (quic.Stream, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.streamList[pathID], s.pathStatusList[pathID]
+}
+
+// Receive Hello ACK Packet
+// Read of Hello ACK is interrupted by the read deadline of the stream when ctx is done
+func (s *Session) receiveHelloAckPacketContext(ctx context.Context, pathID int) error {
+	if ctx.Done() == nil {
+		return s.receiveHelloAckPacket(pathID)
+	}
+
+	stream, _ := s.getStream(pathID)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+		close(stopped)
+	}()
+
+	err := s.receiveHelloAckPacket(pathID)
+	close(stop)
+	<-stopped
+
+	// Receiver of the path reads without deadline
+	stream.SetReadDeadline(time.Time{})
+
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (s *Session) receiveHelloAckPacket(pathID int) error {
+	// Get stream
+	stream, _ := s.getStream(pathID)
+
+	// Receive a packet
+	received, err := ReadPacket(stream, s.config.PacketSize)
+	if err != nil {
+		return err
+	}
+
+	// Parse packet
+	if packet, ok := received.(*HelloAckPacket); ok {
+		// Version and capabilities negotiated by the acceptor
+		if packet.Version > PROTOCOL_VERSION {
+			return fmt.Errorf("%w: version %d of the peer is newer than %d", ErrIncompatiblePeer, packet.Version, PROTOCOL_VERSION)
+		}
+		version, capabilities, err := negotiateProtocol(packet.Version, packet.Capabilities)
+		if err != nil {
+			return err
+		}
+		err = s.setProtocol(version, capabilities, packet.MaxPayloadSize)
+		if err != nil {
+			return err
+		}
+
+		s.handleHelloAckPacket(packet)
+	} else {
+		return fmt.Errorf("%w: packet type (%d) is not Hello ACK", ErrHandshakeFailed, received.GetType())
+	}
+
+	return nil
+}
+
+func (s *Session) StartReceiver(pathID int) {
+	s.mutex.Lock()
+	s.startedList[pathID] = true
+	s.lastRecvTimeList[pathID] = time.Now()
+	keepalive := s.capabilities&CAP_KEEPALIVE != 0
+	s.mutex.Unlock()
+
+	// Start receiver
+	go s.receiver(pathID)
+
+	// Writers waiting for a path can continue
+	s.wakePathWaiters()
+
+	// Start keepalive (if the peer answers PING)
+	if s.config.PingIntervalMs > 0 && keepalive {
+		go s.keepalive(pathID)
+	}
+
+	s.notifyPathEvent(pathID, true, nil)
+}
+
+func (s *Session) wakePathWaiters() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	close(s.pathChan)
+	s.pathChan = make(chan struct{})
+}
+
+// Wait until pathChan is closed (i.e. a path is up after all paths are lost)
+// Returns false if no path is up within Config.ResumeTimeoutMs or the write deadline
+func (s *Session) waitPath(pathChan chan struct{}) bool {
+	s.mutex.Lock()
+	deadline := s.writeDeadline
+	s.mutex.Unlock()
+
+	timeout := time.Duration(s.config.ResumeTimeoutMs) * time.Millisecond
+	if !deadline.IsZero() && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	if timeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-pathChan:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Set a callback for path up/down events
+// The callback is called synchronously by the session, so it should not block
+func (s *Session) SetPathEventHandler(handler func(event PathEvent)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pathEventHandler = handler
+}
+
+func (s *Session) notifyPathEvent(pathID int, up bool, err error) {
+	s.mutex.Lock()
+	handler := s.pathEventHandler
+	started := s.startedList[pathID]
+	addr := s.connectedAddrList[pathID]
+	if started && up {
+		s.pathUps++
+	} else if started {
+		s.pathDowns++
+	}
+	s.mutex.Unlock()
+
+	// Path which failed during handshake is not notified
+	if handler == nil || !started {
+		return
+	}
+
+	handler(PathEvent{PathID: pathID, Addr: addr, Up: up, Err: err})
+}
+
+// Smoothed RTT of the path measured by keepalive probes (0 if not measured yet)
+func (s *Session) PathRTT(pathID int) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if pathID < 0 || pathID >= s.numPath {
+		return 0
+	}
+
+	return s.rttList[pathID]
+}
+
+// Send keepalive probes, and mark the path down if nothing is received
+// during PingIntervalMs * PingMaxLost
+func (s *Session) keepalive(pathID int) {
+	interval := time.Duration(s.config.PingIntervalMs) * time.Millisecond
+	timeout := interval * time.Duration(s.config.PingMaxLost)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mutex.Lock()
+		status := s.pathStatusList[pathID]
+		lastRecvTime := s.lastRecvTimeList[pathID]
+		s.mutex.Unlock()
+
+		if status != PATH_ACTIVE || s.isClosed() || s.isPeerDone() {
+			return
+		}
+
+		if time.Since(lastRecvTime) >= timeout {
+			s.logger.Log("Session.keepalive(): PathID=%d, Nothing is received for %v", pathID, time.Since(lastRecvTime))
+			s.handlePathDown(pathID)
+			return
+		}
+
+		s.sendPingPacket(pathID)
+	}
+}
+
+// Reconnect a lost path as a new path (only initiator can connect a path)
+// The interval doubles after each failed attempt up to RECONNECT_MAX_INTERVAL, and reconnection gives up
+// after Config.ReconnectMaxAttempts attempts or when the peer does not advertise the address anymore
+func (s *Session) reconnect(pathID int) {
+	s.mutex.Lock()
+	initiator, started := s.initiator, s.startedList[pathID]
+	addr, localAddr := s.connectedAddrList[pathID], s.localAddrList[pathID]
+	s.mutex.Unlock()
+
+	if !initiator || !started || s.config.ReconnectIntervalMs == 0 {
+		return
+	}
+
+	// Addresses which are not advertised (e.g. the first path) are reconnected until the attempts run out
+	advertised := s.isAdvertised(addr)
+
+	// Same local interface (any port)
+	if host, _, err := net.SplitHostPort(localAddr); err == nil {
+		localAddr = net.JoinHostPort(host, "0")
+	}
+
+	interval := time.Duration(s.config.ReconnectIntervalMs) * time.Millisecond
+	maxAttempts := s.config.ReconnectMaxAttempts
+
+	go func() {
+		for attempt := 1; maxAttempts == 0 || attempt <= maxAttempts; attempt++ {
+			select {
+			case <-time.After(interval):
+			case <-s.ctx.Done():
+				return
+			}
+
+			if s.isClosed() || s.isPeerDone() || s.isConnected(addr) {
+				return
+			}
+
+			if advertised && !s.isAdvertised(addr) {
+				s.logger.Log("Session.reconnect(): PathID=%d, %s is not advertised anymore", pathID, addr)
+				return
+			}
+
+			err := s.connect(s.ctx, localAddr, addr)
+			if err == nil {
+				s.logger.Log("Session.reconnect(): PathID=%d is reconnected to %s", pathID, addr)
+				return
+			}
+			s.logger.Log("Session.reconnect(): %v", err)
+
+			// Peer does not know the session anymore (e.g. the session is expired by idle timeout)
+			var appErr *quic.ApplicationError
+			if errors.As(err, &appErr) && appErr.ErrorCode == QUIC_ERROR_HANDSHAKE_FAILED {
+				s.mutex.Lock()
+				s.closing = true
+				s.mutex.Unlock()
+
+				s.wakePathWaiters()
+				s.teardown()
+				return
+			}
+
+			if interval < RECONNECT_MAX_INTERVAL {
+				interval *= 2
+				if interval > RECONNECT_MAX_INTERVAL {
+					interval = RECONNECT_MAX_INTERVAL
+				}
+			}
+		}
+
+		s.logger.Log("Session.reconnect(): PathID=%d, Give up reconnecting to %s after %d attempts", pathID, addr, maxAttempts)
+	}()
+}
+
+// Packet receiver
+func (s *Session) receiver(pathID int) {
+	// Get stream
+	stream, _ := s.getStream(pathID)
+	if stream == nil {
+		// Path is closed before its receiver starts
+		return
+	}
+
+	for {
+		// Receive a packet
+		packet, err := ReadPacket(stream, s.config.PacketSize)
+		if err != nil {
+			if s.isClosed() {
+				// Session is closed by Close() or idle timeout
+				return
+			}
+			if _, status := s.getStream(pathID); status == PATH_REMOVED {
+				// Peer closed its side of the removed path
+				s.closeConnection(pathID)
+				return
+			}
+			if s.isPeerClosed(err) {
+				// Peer closed its connections after Goodbye ACK
+				return
+			}
+			// The framing of the stream cannot be trusted anymore if the packet is invalid
+			s.handlePathError(pathID, err)
+			return
+		}
+
+		// Any packet shows that the path is alive
+		s.mutex.Lock()
+		s.lastRecvTimeList[pathID] = time.Now()
+		s.lastRecvTime = s.lastRecvTimeList[pathID]
+		s.mutex.Unlock()
+
+		// Packet Handling
+		switch packet := packet.(type) {
+		// Hello Packet or Hello ACK Packet
+		case *HelloPacket, *HelloAckPacket:
+			// Error case since hello packet is received when the session created
+			s.handlePathError(pathID, fmt.Errorf("%w: unexpected packet type (%d)", ErrHandshakeFailed, packet.GetType()))
+			return
+
+		// Data Packet
+		case *DataPacket:
+			s.mutex.Lock()
+			s.recvBytes[pathID] += uint64(len(packet.Payload))
+			s.recvPackets[pathID]++
+			s.mutex.Unlock()
+
+			s.handleDataPacket(packet, pathID)
+
+		// ACK Packet
+		case *AckPacket:
+			s.handleAckPacket(packet, pathID)
+
+		// Goodbye Packet
+		case *GoodbyePacket:
+			s.handleGoodbyePacket(packet, pathID)
+
+		// Goodbye ACK Packet
+		case *GoodbyeAckPacket:
+			s.handleGoodbyeAckPacket()
+
+		// Add Path Packet
+		case *AddPathPacket:
+			s.handleAddPathPacket(packet)
+
+		// Remove Path Packet
+		case *RemovePathPacket:
+			s.handleRemovePathPacket(pathID)
+
+		// Ping Packet or Pong Packet
+		case *PingPacket:
+			if packet.Type == PING_PACKET {
+				s.sendPongPacket(packet.SeqNumber, pathID)
+			} else {
+				s.handlePongPacket(packet, pathID)
+			}
+		}
+	}
+}
+
+// Tear down only the path where an error occurs
+func (s *Session) handlePathError(pathID int, err error) {
+	s.mutex.Lock()
+	addr := s.connectedAddrList[pathID]
+	s.mutex.Unlock()
+
+	err = &PathError{Op: "receive", PathID: pathID, Addr: addr, Err: err}
+	s.logger.Log("Session.receiver(): %v", err)
+	s.handlePathFailure(pathID, err)
+}
+
+// Send Hello Packet
+func (s *Session) sendHelloPacket(pathID int) {
+	s.logger.Log("Session.SendHelloPacket(): SessionID=%d", s.SessionID)
+
+	// Additional path is authenticated by the key of the first path
+	var pathMac [PATH_MAC_LEN]byte
+	s.mutex.Lock()
+	sessionID := s.SessionID
+	joinKey := s.joinKey
+	conn := s.connectionList[pathID]
+	s.mutex.Unlock()
+
+	if sessionID != 0 {
+		var err error
+		pathMac, err = computePathMac(joinKey, sessionID, conn)
+		if err != nil {
+			s.logger.Log("Session.SendHelloPacket(): %v", err)
+		}
+	}
+
+	// Create Hello Packet
+	// Session ID of first hello packet is 0.
+	// After first hello packet, session ID is greater than 0 (assigned by server).
+	packet := CreateHelloPacket(sessionID, LOCAL_CAPABILITIES, maxRecvPayloadSize(s.config), pathMac, s.recvBuffer.GetExpectedSeqNumber())
+	// Send packet
+	s.SendPacket(packet, pathID)
+}
+
+// Send Hello Ack Packet
+func (s *Session) SendHelloAckPacket(pathID int) {
+	s.logger.Log("Session.SendHelloAckPacket(): SessionID=%d", s.SessionID)
+
+	nicInfos := s.getNicInfo()
+
+	s.mutex.Lock()
+	token := s.token
+	version, capabilities := s.version, s.capabilities
+	maxLen := s.config.PacketSize
+	if s.peerPacketSize > 0 && s.peerPacketSize < maxLen {
+		maxLen = s.peerPacketSize
+	}
+	s.mutex.Unlock()
+
+	// Hello ACK should be received by the peer at once
+	nicInfos, dropped := fitNicInfos(nicInfos, maxLen)
+	if len(dropped) > 0 {
+		s.logger.Log("Session.SendHelloAckPacket(): %d of %d addresses are not advertised (Hello ACK is limited to %d bytes)", len(dropped), len(nicInfos)+len(dropped), maxLen)
+		for i := range dropped {
+			s.logger.Log("Session.SendHelloAckPacket(): %s is not advertised", dropped[i].String())
+		}
+	}
+
+	// Create Hello ACK Packet
+	packet := CreateHelloAckPacket(version, s.SessionID, capabilities, maxRecvPayloadSize(s.config), token, s.recvBuffer.GetExpectedSeqNumber(), nicInfos)
+	// Send packet
+	s.SendPacket(packet, pathID)
+}
+
+// Send Data Packet
+func (s *Session) sendDataPacket(seq uint32, payload []byte, pathID int) error {
+	s.logger.Log("Session.sendDataPacket(): SessionID=%d, PathID=%d, Seq=%d, Len.Payload=%d", s.SessionID, pathID, seq, len(payload))
+
+	// Create Data Packet
+	packet := CreateDataPacket(s.SessionID, pathID, seq, payload)
+	// Keep the packet until it is acknowledged
+	s.sendBuffer.PushPacket(packet, pathID)
+
+	// Send packet
+	err := s.SendPacket(packet, pathID)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.sentBytes[pathID] += uint64(len(payload))
+	s.sentPackets[pathID]++
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// Send ACK Packet
+func (s *Session) sendAckPacket(seq uint32, pathID int) {
+	packet := CreateAckPacket(s.SessionID, seq)
+	// Send packet
+	s.SendPacket(packet, pathID)
+}
+
+// Send Goodbye Packet
+func (s *Session) sendGoodbyePacket(finalSeq uint32, pathID int) error {
+	s.logger.Log("Session.sendGoodbyePacket(): SessionID=%d, PathID=%d, FinalSeq=%d", s.SessionID, pathID, finalSeq)
+
+	packet := CreateGoodbyePacket(s.SessionID, finalSeq)
+	// Send packet
+	return s.SendPacket(packet, pathID)
+}
+
+// Send Goodbye ACK Packet
+func (s *Session) sendGoodbyeAckPacket(pathID int) error {
+	s.logger.Log("Session.sendGoodbyeAckPacket(): SessionID=%d, PathID=%d", s.SessionID, pathID)
+
+	packet := CreateGoodbyeAckPacket(s.SessionID)
+	// Send packet
+	return s.SendPacket(packet, pathID)
 }
 
-func (s *Session) Connect(addr string) {
-	// Connect to Master listener
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
-	if err != nil {
-		panic(err)
-	}
+// Send Add Path Packet through any active path
+func (s *Session) sendAddPathPacket(addr string) error {
+	s.logger.Log("Session.sendAddPathPacket(): SessionID=%d, Addr=%s", s.SessionID, addr)
 
-	// TODO bind my IP?
-	ip4 := net.ParseIP("127.0.0.1").To4()
-	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip4, Port: 0})
+	nicInfo, err := CreateNicInfo(NIC_TYPE_UNKNOWN, 0, addr)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	// TLS configuration
-	tlsConf := &tls.Config{
-		InsecureSkipVerify: true,
-		NextProtos:         []string{"socket-programming"},
+	s.mutex.Lock()
+	peerLoopback := s.peerLoopbackLocked()
+	s.mutex.Unlock()
+	if isLoopbackAddr(addr) && !peerLoopback {
+		return &PathError{Op: "add", PathID: -1, Addr: addr, Err: fmt.Errorf("%w: loopback address for a remote peer", ErrInvalidAddress)}
 	}
 
-	// QUIC Dial
-	quicSess, err := quic.Dial(udpConn, udpAddr, addr, tlsConf, nil)
-	if err != nil {
-		panic(err)
+	for _, info := range s.getNicInfo() {
+		if info.String() == nicInfo.String() {
+			nicInfo = info
+		}
 	}
 
-	// QUIC OpenStreamSync
-	quicStream, err := quicSess.OpenStreamSync(context.Background())
-	if err != nil {
-		panic(err)
+	packet := CreateAddPathPacket(s.SessionID, nicInfo)
+	// Send packet
+	for _, pathID := range s.PathIDs() {
+		if s.SendPacket(packet, pathID) == nil {
+			return nil
+		}
 	}
 
-	Log("Session.Connect(): Connect to %s (%s)", addr, quicSess.RemoteAddr().String())
-
-	// Add a created session into session map
-	pathID := s.AddStream(quicStream, quicSess.RemoteAddr().String())
-
-	// Send Hello Packet
-	s.sendHelloPacket(pathID)
+	return ErrNoAvailablePath
+}
 
-	// Receive hello ack packet
-	s.receiveHelloAckPacket(pathID)
+// Send Ping Packet
+func (s *Session) sendPingPacket(pathID int) {
+	s.mutex.Lock()
+	s.pingSeqList[pathID]++
+	seq := s.pingSeqList[pathID]
+	s.pingTimeList[pathID] = time.Now()
+	s.mutex.Unlock()
+
+	packet := CreatePingPacket(s.SessionID, seq)
+	// Send packet
+	s.SendPacket(packet, pathID)
+}
 
-	// Start receiver
-	s.StartReceiver(pathID)
+// Send Pong Packet through the path where ping is received
+func (s *Session) sendPongPacket(seq uint32, pathID int) {
+	packet := CreatePongPacket(s.SessionID, seq)
+	// Send packet
+	s.SendPacket(packet, pathID)
 }
 
-func (s *Session) AddStream(stream quic.Stream, connectedAddr string) int {
-	s.streamList = append(s.streamList, stream)
-	s.connectedAddrList = append(s.connectedAddrList, connectedAddr)
-	s.numPath++
-	s.sentBytes = append(s.sentBytes, 0)
-	s.recvBytes = append(s.recvBytes, 0)
+// Send Remove Path Packet through the removed path
+func (s *Session) sendRemovePathPacket(pathID int) {
+	s.logger.Log("Session.sendRemovePathPacket(): SessionID=%d, PathID=%d", s.SessionID, pathID)
 
-	return (s.numPath - 1)
+	packet := CreateRemovePathPacket(s.SessionID)
+	// Send packet
+	s.SendPacket(packet, pathID)
 }
 
-// Receive Hello ACK Packet
-func (s *Session) receiveHelloAckPacket(pathID int) {
-	// Get stream
+// Send a packet through the path
+func (s *Session) SendPacket(packet Packet, pathID int) error {
+	s.mutex.Lock()
 	stream := s.streamList[pathID]
+	streamMutex := s.streamMutexList[pathID]
+	status := s.pathStatusList[pathID]
+	s.mutex.Unlock()
 
-	buf := make([]byte, PACKET_SIZE)
-
-	// Read packet type and length
-	_, err := stream.Read(buf[:5])
-	if err != nil {
-		panic(err)
+	if status != PATH_ACTIVE {
+		return &PathError{Op: "send", PathID: pathID, Err: ErrPathClosed}
 	}
 
-	r := bytes.NewReader(buf[:5])
-	packetType, _ := r.ReadByte()
-	packetLength, _ := ReadUint16(r)
+	// Packets of different go routines (e.g. ACKs and reinjection) should not be interleaved
+	streamMutex.Lock()
+	err := WritePacket(stream, packet)
+	streamMutex.Unlock()
 
-	// Read remaing data
-	_, err = stream.Read(buf[5:packetLength]) // Read after field of packet length
 	if err != nil {
-		panic(err)
+		log.Println(err)
 	}
 
-	// Parse packet
-	reader := bytes.NewReader(buf)
-	if packetType == HELLO_ACK_PACKET {
-		packet, err := ParseHelloAckPacket(reader)
+	return err
+}
+
+// Handle Hello Ack Packet
+func (s *Session) handleHelloAckPacket(packet *HelloAckPacket) {
+	s.logger.Log("Session.handleHelloAckPacket(): SessionID=%d", packet.SessionID)
+
+	// Set to session ID assigned by server
+	firstPath := (s.SessionID == 0)
+	if firstPath {
+		s.mutex.Lock()
+		s.SessionID = packet.SessionID
+		s.token = packet.Token
+		joinKey, err := deriveJoinKey(s.connectionList[0], packet.Token)
 		if err != nil {
-			panic(err)
+			s.logger.Log("Session.handleHelloAckPacket(): %v", err)
 		}
-		s.handleHelloAckPacket(packet)
-	} else {
-		panic(fmt.Sprintf("Session.receiveHelloAckPacket(): Unknown packet type (%d)", packetType))
+		s.joinKey = joinKey
+		s.mutex.Unlock()
 	}
-}
 
-func (s *Session) StartReceiver(pathID int) {
-	// Start receiver
-	go s.receiver(pathID)
-}
+	// Set numPath for scheduler -> scheduler begins to consider an added path
+	s.updateSchedulerPaths()
 
-// TODO implement DeleteStream()
+	// Packets lost with the previous paths are resent (session resumption)
+	if !firstPath {
+		s.resume(packet.AckSeqNumber)
+	}
 
-// Packet receiver
-func (s *Session) receiver(pathID int) {
-	// Get stream
-	stream := s.streamList[pathID]
+	// Group addresses by network interface (NicID 0 means an unknown interface)
+	// Every Hello ACK carries the current addresses of the peer, so they replace the advertised addresses
+	nicGroups := make([][]NicInfo, 0)
+	groupIndex := make(map[uint16]int)
+	addrList := make([]string, 0, len(packet.NicInfos))
+	for i, nicInfo := range packet.NicInfos {
+		s.logger.Log("Session.handleHelloAckPacket(): NicInfo[%d]=%s (NicID=%d, %s)", i, nicInfo.String(), nicInfo.NicID, NicTypeString(nicInfo.Type))
+		addrList = append(addrList, nicInfo.String())
 
-	for {
-		buf := make([]byte, PACKET_SIZE)
+		if index, exists := groupIndex[nicInfo.NicID]; exists && nicInfo.NicID != 0 {
+			nicGroups[index] = append(nicGroups[index], nicInfo)
+		} else {
+			groupIndex[nicInfo.NicID] = len(nicGroups)
+			nicGroups = append(nicGroups, []NicInfo{nicInfo})
+		}
+	}
 
-		// Receive packet type and length
-		_, err := stream.Read(buf[:5])
-		if err != nil {
-			panic(err)
+	s.mutex.Lock()
+	s.advertisedAddrList = addrList
+	s.mutex.Unlock()
+
+	// Additional paths are connected only once by the first path
+	// (otherwise, they are connected by AddPath() adaptively)
+	if !firstPath || !s.config.AutoConnect {
+		return
+	}
+
+	// One path for each interface
+	// Interfaces are connected in background, so that the first path starts its receiver and keepalive
+	// without waiting for them (an unreachable address takes the QUIC handshake timeout)
+	for _, nicInfos := range nicGroups {
+		connected := false
+		for _, nicInfo := range nicInfos {
+			if s.isConnected(nicInfo.String()) {
+				connected = true
+			}
 		}
 
-		r := bytes.NewReader(buf[:5])
-		packetType, _ := r.ReadByte()
-		packetLength, _ := ReadUint16(r)
+		// If not yet connected interface is found, connect to that interface
+		// (failure of an additional path does not fail the session)
+		if !connected {
+			go func(nicInfos []NicInfo) {
+				err := s.connectNic(s.ctx, nicInfos)
+				if err != nil {
+					s.logger.Log("Session.handleHelloAckPacket(): %v", err)
+				}
+			}(nicInfos)
+		}
+	}
+}
 
-		// Receive remaing data
-		_, err = stream.Read(buf[5:packetLength]) // Read after field of packet length
-		if err != nil {
-			panic(err)
+// Whether the peer advertises the address
+func (s *Session) isAdvertised(addr string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, advertisedAddr := range s.advertisedAddrList {
+		if advertisedAddr == addr {
+			return true
 		}
+	}
 
-		reader := bytes.NewReader(buf)
+	return false
+}
 
-		// Packet Handling
-		switch packetType {
-		// Hello Packet or Hello ACK Packet
-		case HELLO_PACKET:
-		case HELLO_ACK_PACKET:
-			// Error case since hello packet is received when the session created
-			panic(fmt.Sprintf("Session.receiver(): Error! PathID=%d, Packet Type=%d !!!!!!!!!!!!!!!!!!!!!\n", pathID, packetType))
+func (s *Session) addAdvertisedAddr(addr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-		// Data Packet
-		case DATA_PACKET:
-			packet, err := ParseDataPacket(reader)
-			if err != nil {
-				panic(err)
-			}
+	for _, advertisedAddr := range s.advertisedAddrList {
+		if advertisedAddr == addr {
+			return
+		}
+	}
+	s.advertisedAddrList = append(s.advertisedAddrList, addr)
+}
 
-			s.recvBytes[pathID] += uint32(packet.Length - DATA_PACKET_HEADER_LEN)
+// Handle Add Path Packet (the peer called AddPath())
+func (s *Session) handleAddPathPacket(packet *AddPathPacket) {
+	addr := packet.NicInfo.String()
+	s.logger.Log("Session.handleAddPathPacket(): SessionID=%d, Addr=%s", s.SessionID, addr)
 
-			s.handleDataPacket(packet)
+	s.mutex.Lock()
+	initiator := s.initiator
+	s.mutex.Unlock()
 
-		// Goodbye Packet
-		case GOODBYE_PACKET:
-			packet, err := ParseGoodbyePacket(reader)
-			if err != nil {
-				panic(err)
-			}
-			s.handleGoodbyePacket(packet)
+	if !initiator {
+		s.logger.Log("Session.handleAddPathPacket(): Acceptor cannot connect a new path")
+		return
+	}
 
-		default:
-			panic(fmt.Sprintf("Unknown packet type: %d \n", packetType))
+	s.addAdvertisedAddr(addr)
+	if s.isConnected(addr) {
+		return
+	}
+
+	// Receiver should not be blocked by the handshake of a new path
+	go func() {
+		err := s.connect(s.ctx, "", addr)
+		if err != nil {
+			s.logger.Log("Session.handleAddPathPacket(): %v", err)
 		}
+	}()
+}
+
+// Handle Remove Path Packet (the peer called RemovePath())
+func (s *Session) handleRemovePathPacket(pathID int) {
+	s.logger.Log("Session.handleRemovePathPacket(): SessionID=%d, PathID=%d", s.SessionID, pathID)
+
+	s.removePath(pathID)
+}
+
+// Handle Data Packet
+func (s *Session) handleDataPacket(packet *DataPacket, pathID int) {
+	s.recvBuffer.PushPacket(packet)
+
+	// Acknowledge through the path where the packet is received
+	// Duplicate packets are also acknowledged since the previous ACK may be lost with a failed path
+	s.sendAckPacket(packet.SeqNumber, pathID)
+}
+
+// Handle ACK Packet
+func (s *Session) handleAckPacket(packet *AckPacket, pathID int) {
+	dataPacket, sentPathID, rtt := s.sendBuffer.AckPacket(packet.SeqNumber)
+
+	// Network condition is measured only if the ACK comes back through the path where the packet was sent
+	if dataPacket != nil && sentPathID == pathID {
+		s.getScheduler().UpdatePathCondition(pathID, rtt, uint32(len(dataPacket.Payload)))
 	}
 }
 
-// Send Hello Packet
-func (s *Session) sendHelloPacket(pathID int) {
-	Log("Session.SendHelloPacket(): SessionID=%d", s.SessionID)
+// Handle Pong Packet (RTT of the path is measured by the last ping)
+func (s *Session) handlePongPacket(packet *PingPacket, pathID int) {
+	s.mutex.Lock()
+	if packet.SeqNumber != s.pingSeqList[pathID] {
+		s.mutex.Unlock()
+		return
+	}
 
-	// Create Hello Packet and covert into byte[]
-	// Session ID of first hello packet is 0.
-	// After first hello packet, session ID is greater than 0 (assigned by server).
-	packet := CreateHelloPacket(s.SessionID)
-	b := &bytes.Buffer{}
-	packet.Write(b)
+	rtt := time.Since(s.pingTimeList[pathID])
+	if s.rttList[pathID] == 0 {
+		s.rttList[pathID] = rtt
+	} else {
+		s.rttList[pathID] = time.Duration((1-SCHED_RTT_ALPHA)*float64(s.rttList[pathID]) + SCHED_RTT_ALPHA*float64(rtt))
+	}
+	s.mutex.Unlock()
 
-	// Send bytes of packet
-	s.SendPacket(b.Bytes(), pathID)
+	// Scheduler also knows RTT of an idle path
+	s.getScheduler().UpdatePathCondition(pathID, rtt, 0)
 }
 
-// Send Hello Ack Packet
-func (s *Session) SendHelloAckPacket(pathID int) {
-	Log("Session.SendHelloAckPacket(): SessionID=%d", s.SessionID)
+// Handle a failed path:
+// exclude the path from scheduling and reinject its unacknowledged packets into surviving paths
+func (s *Session) handlePathFailure(pathID int, err error) {
+	s.failPath(pathID, PATH_FAILED, err)
+}
 
-	// TODO: Set Nic Info
-	nicInfos := s.getNicInfo()
+// Handle a path where nothing is received during PingIntervalMs * PingMaxLost
+func (s *Session) handlePathDown(pathID int) {
+	s.failPath(pathID, PATH_DOWN, ErrPathDown)
+}
+
+func (s *Session) failPath(pathID int, status int, err error) {
+	s.mutex.Lock()
+	if s.pathStatusList[pathID] != PATH_ACTIVE {
+		s.mutex.Unlock()
+
+		// Packets can be sent through the path while it is being failed or removed
+		s.getScheduler().SetPathAvailable(pathID, false)
+		s.reinjectPackets(pathID)
+		return
+	}
+	s.pathStatusList[pathID] = status
+	stream := s.streamList[pathID]
+	s.mutex.Unlock()
+
+	s.logger.Log("Session.handlePathFailure(): SessionID=%d, PathID=%d, Status=%d, %v", s.SessionID, pathID, status, err)
+
+	s.getScheduler().SetPathAvailable(pathID, false)
 
-	// Create Hello ACK Packet and covert into byte[]
-	packet := CreateHelloAckPacket(s.SessionID, nicInfos)
-	b := &bytes.Buffer{}
-	packet.Write(b)
+	// Release a receiver blocked on the stream
+	stream.CancelRead(0)
+	stream.CancelWrite(0)
 
-	// Send bytes of packet
-	s.SendPacket(b.Bytes(), pathID)
+	// QUIC connection is closed, so that the peer also notices the failure
+	s.closeConnection(pathID)
+
+	s.reinjectPackets(pathID)
+
+	s.notifyPathEvent(pathID, false, err)
+
+	// Lost path is reconnected as a new path
+	s.reconnect(pathID)
 }
 
-// Send Data Packet
-func (s *Session) sendDataPacket(payload []byte, pathID int) {
-	Log("Session.sendDataPacket(): SessionID=%d, PathID=%d, Len.Payload=%d", s.SessionID, pathID, len(payload))
+// Handle a removed path:
+// same as a failed path, but packets already written into the stream are still delivered
+func (s *Session) removePath(pathID int) {
+	s.mutex.Lock()
+	if s.pathStatusList[pathID] != PATH_ACTIVE {
+		s.mutex.Unlock()
+		return
+	}
+	s.pathStatusList[pathID] = PATH_REMOVED
+	stream := s.streamList[pathID]
+	s.mutex.Unlock()
+
+	s.logger.Log("Session.removePath(): SessionID=%d, PathID=%d", s.SessionID, pathID)
+
+	s.getScheduler().SetPathAvailable(pathID, false)
 
-	// Create Data Packet and covert into byte[]
-	packet := CreateDataPacket(s.SessionID, pathID, s.sequenceNumber, payload)
-	b := &bytes.Buffer{}
-	packet.Write(b)
+	// Send FIN after packets already written (receiver is terminated by FIN of the peer)
+	stream.Close()
 
-	// Send bytes of packet
-	s.SendPacket(b.Bytes(), pathID)
+	s.reinjectPackets(pathID)
+
+	s.notifyPathEvent(pathID, false, nil)
 }
 
-// Send Goodbye Packet
-func (s *Session) sendGoodbyePacket(pathID int) {
-	Log("Session.sendGoodbyePacket(): SessionID=%d", s.SessionID)
+// Reinject packets in flight on a failed or removed path
+func (s *Session) reinjectPackets(pathID int) {
+	packets := s.sendBuffer.GetPackets(pathID)
+	for _, packet := range packets {
+		newPathIDs := s.getScheduler().Scheduling(uint32(len(packet.Payload)))
+		if len(newPathIDs) == 0 {
+			s.logger.Log("Session.reinjectPackets(): No available path! %d packets are not acknowledged", s.sendBuffer.GetLength())
+			return
+		}
+
+		s.mutex.Lock()
+		s.reinjectedPackets[pathID]++
+		s.mutex.Unlock()
 
-	packet := CreateGoodbyePacket(s.SessionID)
-	b := &bytes.Buffer{}
-	packet.Write(b)
+		for _, newPathID := range newPathIDs {
+			s.logger.Log("Session.reinjectPackets(): Reinject packet (Seq=%d) from PathID=%d to PathID=%d", packet.SeqNumber, pathID, newPathID)
 
-	// Send bytes of packet
-	s.SendPacket(b.Bytes(), pathID)
+			err := s.sendDataPacket(packet.SeqNumber, packet.Payload, newPathID)
+			if err != nil {
+				// the packet is moved to the new path, so it is reinjected again
+				s.handlePathFailure(newPathID, err)
+			}
+		}
+	}
 }
 
-func (s *Session) SendPacket(packet []byte, pathID int) {
-	stream := s.streamList[pathID]
-	_, err := stream.Write(packet)
-	if err != nil {
-		log.Println(err)
+// Resume transmission through a new path
+// Packets delivered to the peer (before ackSeq) are released, and packets left on lost paths are resent
+// (they cannot be reinjected while all paths are lost)
+func (s *Session) resume(ackSeq uint32) {
+	count := s.sendBuffer.AckPacketsBefore(ackSeq)
+
+	s.mutex.Lock()
+	lostPathIDs := make([]int, 0)
+	for pathID, status := range s.pathStatusList {
+		if status != PATH_ACTIVE {
+			lostPathIDs = append(lostPathIDs, pathID)
+		}
+	}
+	s.mutex.Unlock()
+
+	s.logger.Log("Session.resume(): SessionID=%d, AckSeq=%d, Released=%d, Unacknowledged=%d", s.SessionID, ackSeq, count, s.sendBuffer.GetLength())
+
+	for _, pathID := range lostPathIDs {
+		s.reinjectPackets(pathID)
 	}
 }
 
-// Handle Hello Ack Packet
-func (s *Session) handleHelloAckPacket(packet *HelloAckPacket) {
-	Log("Session.handleHelloAckPacket(): SessionID=%d", packet.SessionID)
+// MAC presented by Hello of an additional path or resumption
+func (s *Session) checkPathMac(pathMac [PATH_MAC_LEN]byte, conn quic.Connection) bool {
+	s.mutex.Lock()
+	joinKey := s.joinKey
+	s.mutex.Unlock()
 
-	// Set to session ID assigned by server
-	if s.SessionID == 0 {
-		s.SessionID = packet.SessionID
+	if joinKey == nil {
+		return false
 	}
 
-	// Set numPath for scheduler -> scheduler begins to consider an added path
-	s.scheduler.SetNumPath(s.numPath)
+	expected, err := computePathMac(joinKey, s.SessionID, conn)
+	if err != nil {
+		s.logger.Log("Session.checkPathMac(): %v", err)
+		return false
+	}
 
-	// TODO Now we establish all connections immediately
-	// but we have to change to establish connection adaptively during transmission
-	for i, nicInfo := range packet.NicInfos {
-		nicAddr := string(nicInfo.Addr)
-		Log("Session.handleHelloAckPacket(): NicInfo[%d]=%s", i, nicAddr)
+	return hmac.Equal(pathMac[:], expected[:])
+}
 
-		// Find the address not yet connected
-		connected := false
-		for _, conAddr := range s.connectedAddrList {
-			if nicAddr == conAddr {
-				connected = true
-			}
+// Goodbye Packet
+func (s *Session) handleGoodbyePacket(packet *GoodbyePacket, pathID int) {
+	s.logger.Log("Session.handleGoodbyePacket(): SessionID=%d, PathID=%d, FinalSeq=%d", s.SessionID, pathID, packet.FinalSeqNumber)
+	s.mutex.Lock()
+	s.goodbye = true
+	s.mutex.Unlock()
+
+	// Readers get io.EOF after remaining data
+	// (data packets of other paths may arrive after goodbye)
+	s.recvBuffer.CloseAfter(packet.FinalSeqNumber)
+
+	// Acknowledge after all data packets are received
+	// (Goodbye is sent through all paths, and each of them is acknowledged)
+	go func() {
+		<-s.recvBuffer.Closed()
+
+		s.mutex.Lock()
+		closed := s.closed
+		s.mutex.Unlock()
+		if closed {
+			return
 		}
 
-		// If not yet connected address is found, connect to that address
-		if !connected {
-			s.Connect(nicAddr)
+		if s.sendGoodbyeAckPacket(pathID) == nil {
+			return
+		}
+		for _, otherPathID := range s.PathIDs() {
+			if s.sendGoodbyeAckPacket(otherPathID) == nil {
+				return
+			}
 		}
+		s.logger.Log("Session.handleGoodbyePacket(): Goodbye ACK cannot be sent (SessionID=%d)", s.SessionID)
+	}()
+}
+
+// Goodbye ACK Packet
+func (s *Session) handleGoodbyeAckPacket() {
+	s.logger.Log("Session.handleGoodbyeAckPacket(): SessionID=%d", s.SessionID)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.goodbyeAcked {
+		s.goodbyeAcked = true
+		close(s.goodbyeAckChan)
 	}
 }
 
-// Handle Data Packet
-func (s *Session) handleDataPacket(packet *DataPacket) {
-	s.recvBuffer.PushPacket(packet)
+// Whether the session is closed by Close() or idle timeout
+// (after Goodbye of the peer, paths are still failed and reinjected until the data of both sides is delivered)
+func (s *Session) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.closed
 }
 
-// Goodbye Packet
-func (s *Session) handleGoodbyePacket(packet *GoodbyePacket) {
-	// Terminate receiver go routine
-	Log("Session.handleGoodbyePacket()")
-	s.goodbye = true
+// Whether the peer said goodbye and all of its data is received
+// (Goodbye ACK is sent, and the peer does not read our data anymore)
+func (s *Session) isPeerDone() bool {
+	s.mutex.Lock()
+	goodbye := s.goodbye
+	s.mutex.Unlock()
+
+	if !goodbye {
+		return false
+	}
+
+	select {
+	case <-s.recvBuffer.Closed():
+		return true
+	default:
+		return false
+	}
+}
+
+// Whether the path error is caused by the peer closing its connections after Goodbye ACK
+func (s *Session) isPeerClosed(err error) bool {
+	var appErr *quic.ApplicationError
+	return errors.As(err, &appErr) && appErr.Remote && appErr.ErrorCode == 0 && s.isPeerDone()
 }
 
 // Read data
+// Blocks until data is received, and returns io.EOF when the session is closed
 func (s *Session) Read(buf []byte) (int, error) {
-	// Check whether recvBuffer is empty
-	// TODO modify???
-	for s.recvBuffer.IsEmpty() && !s.goodbye {
-	}
+	return s.recvBuffer.Read(buf)
+}
+
+// Set deadline for Read() (zero value means no deadline)
+// Read() returns os.ErrDeadlineExceeded after the deadline
+func (s *Session) SetReadDeadline(t time.Time) error {
+	s.recvBuffer.SetReadDeadline(t)
+	return nil
+}
+
+// Set deadline for Write() (zero value means no deadline)
+// The deadline is checked before each data packet, since a packet partially written
+// into a QUIC stream would break the framing of the stream
+func (s *Session) SetWriteDeadline(t time.Time) error {
+	s.mutex.Lock()
+	s.writeDeadline = t
+	s.mutex.Unlock()
+	return nil
+}
+
+// Set deadlines for both Read() and Write()
+func (s *Session) SetDeadline(t time.Time) error {
+	s.SetReadDeadline(t)
+	s.SetWriteDeadline(t)
+	return nil
+}
 
-	readLen := s.recvBuffer.Read(buf)
+// Addresses which the session manager listens on
+func (s *Session) LocalAddr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	return readLen, nil
+	addrList := make([]string, len(s.listenAddrList))
+	copy(addrList, s.listenAddrList)
+
+	return &SessionAddr{AddrList: addrList}
+}
+
+// Connected addresses of all paths
+func (s *Session) RemoteAddr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	addrList := make([]string, len(s.connectedAddrList))
+	copy(addrList, s.connectedAddrList)
+
+	return &SessionAddr{AddrList: addrList}
 }
 
 // Send data
 func (s *Session) Write(buf []byte) (int, error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	return s.write(buf)
+}
+
+// Send data (writeMutex should be held by the caller)
+func (s *Session) write(buf []byte) (int, error) {
 	start, end := 0, 0
-	pathID := 0
 	total := 0
 
 	for start < len(buf) {
+		s.mutex.Lock()
+		closed, deadline := s.closed || s.closing, s.writeDeadline
+		maxPayloadSize := s.payloadSize
+		s.mutex.Unlock()
+
+		if closed {
+			return total, net.ErrClosed
+		}
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return total, os.ErrDeadlineExceeded
+		}
+
 		// Determine the range of payload
-		if start+DATA_PACKET_PAYLOAD_SIZE < len(buf) {
-			end = start + DATA_PACKET_PAYLOAD_SIZE
+		if start+maxPayloadSize < len(buf) {
+			end = start + maxPayloadSize
 		} else {
 			end = len(buf)
 		}
 
 		payloadSize := uint32(end - start)
-		total += int(payloadSize)
 
-		// Scheduling
-		pathID = s.scheduler.Scheduling(payloadSize)
+		// Scheduling (redundant scheduler selects multiple paths)
+		s.mutex.Lock()
+		pathChan := s.pathChan
+		s.mutex.Unlock()
+		pathIDs := s.getScheduler().Scheduling(payloadSize)
+		if len(pathIDs) == 0 {
+			// All paths are lost, but they may be reconnected (session resumption)
+			if s.waitPath(pathChan) {
+				continue
+			}
+			return total, ErrNoAvailablePath
+		}
+		total += int(payloadSize)
 
 		// Send data packet
-		s.sendDataPacket(buf[start:end], pathID)
-		s.sentBytes[pathID] += payloadSize
+		for _, pathID := range pathIDs {
+			err := s.sendDataPacket(s.sequenceNumber, buf[start:end], pathID)
+			if err != nil {
+				// The packet is kept in sendBuffer, so it is reinjected into another path
+				s.handlePathFailure(pathID, err)
+			}
+		}
 		s.sequenceNumber++
 
 		start = end
@@ -356,23 +1810,193 @@ func (s *Session) Write(buf []byte) (int, error) {
 	return total, nil
 }
 
-// TODO this function should be modified to get NIC information automatically
+// NIC information of listen addresses (see Config.DiscoverNics)
+// Loopback addresses are not advertised unless the peer is connected over loopback
 func (s *Session) getNicInfo() []NicInfo {
-	nicInfos := make([]NicInfo, len(s.listenAddrList))
-	for i := 0; i < len(s.listenAddrList); i++ {
-		nicInfos[i].Type = 0
-		nicInfos[i].AddrLen = byte(len(s.listenAddrList[i]))
-		nicInfos[i].Addr = []byte(s.listenAddrList[i])
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	nicInfos := make([]NicInfo, 0, len(s.nicInfoList))
+	for _, nicInfo := range s.nicInfoList {
+		if net.IP(nicInfo.Addr).IsLoopback() && !s.peerLoopbackLocked() {
+			continue
+		}
+		nicInfos = append(nicInfos, nicInfo)
 	}
 	return nicInfos
 }
 
-// TODO
-func (s *Session) Close() {
-	s.sendGoodbyePacket(0)
+// Whether the first path is connected over loopback (s.mutex is held by the caller)
+func (s *Session) peerLoopbackLocked() bool {
+	return len(s.connectedAddrList) > 0 && isLoopbackAddr(s.connectedAddrList[0])
+}
+
+// Close session (waits for Goodbye ACK up to CLOSE_TIMEOUT, see CloseContext())
+// Read() returns io.EOF after remaining data, and Write() returns net.ErrClosed
+func (s *Session) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), CLOSE_TIMEOUT)
+	defer cancel()
+
+	return s.CloseContext(ctx)
+}
+
+// Close session gracefully
+// Goodbye carries the final sequence number, and the peer acknowledges it after all data packets are received.
+// Blocks until Goodbye ACK is received or ctx is done (the session is closed in both cases)
+func (s *Session) CloseContext(ctx context.Context) error {
+	s.mutex.Lock()
+	if s.closing || s.closed {
+		s.mutex.Unlock()
+		return net.ErrClosed
+	}
+	s.closing = true
+	s.mutex.Unlock()
+
+	// Release writers waiting for a path
+	s.wakePathWaiters()
+
+	// Wait for Write() in progress, so that no data packet follows Goodbye
+	s.writeMutex.Lock()
+	finalSeq := s.sequenceNumber
+	s.writeMutex.Unlock()
+
+	// Goodbye is sent through all active paths, so that it survives a path failure
+	sent := false
+	for _, pathID := range s.PathIDs() {
+		if s.sendGoodbyePacket(finalSeq, pathID) == nil {
+			sent = true
+		}
+	}
+
+	var err error
+	if sent {
+		err = s.waitGoodbyeAck(ctx)
+	} else {
+		err = ErrNoAvailablePath
+	}
+
+	s.teardown()
+
+	return err
+}
+
+// Wait for Goodbye ACK
+// Returns early if the peer closed all connections (e.g. the peer is closed first)
+func (s *Session) waitGoodbyeAck(ctx context.Context) error {
+	s.mutex.Lock()
+	goodbyeAckChan := s.goodbyeAckChan
+	connectionList := make([]quic.Connection, len(s.connectionList))
+	copy(connectionList, s.connectionList)
+	s.mutex.Unlock()
+
+	connDone := make(chan struct{})
+	go func() {
+		for _, conn := range connectionList {
+			if conn != nil {
+				<-conn.Context().Done()
+			}
+		}
+		close(connDone)
+	}()
+
+	select {
+	case <-goodbyeAckChan:
+		return nil
+
+	case <-connDone:
+		s.mutex.Lock()
+		goodbye := s.goodbye
+		s.mutex.Unlock()
+
+		// The peer is closed first, so it does not read our data anymore
+		if goodbye {
+			return nil
+		}
+		return ErrNoAvailablePath
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close the session if nothing is received through any path during Config.IdleTimeoutMs
+// (e.g. all paths are lost and the peer does not resume the session)
+func (s *Session) monitorIdle() {
+	timeout := time.Duration(s.config.IdleTimeoutMs) * time.Millisecond
+
+	ticker := time.NewTicker(IDLE_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mutex.Lock()
+		closed, lastRecvTime := s.closed, s.lastRecvTime
+		idle := time.Since(lastRecvTime) >= timeout
+		if idle {
+			s.closing = true
+		}
+		s.mutex.Unlock()
+
+		if closed {
+			return
+		}
+
+		if idle {
+			s.logger.Log("Session.monitorIdle(): SessionID=%d, Nothing is received for %v", s.SessionID, time.Since(lastRecvTime))
+
+			s.wakePathWaiters()
+
+			// Goodbye cannot be acknowledged by an idle peer, so it is not waited for
+			s.writeMutex.Lock()
+			finalSeq := s.sequenceNumber
+			s.writeMutex.Unlock()
+			for _, pathID := range s.PathIDs() {
+				s.sendGoodbyePacket(finalSeq, pathID)
+			}
+
+			s.teardown()
+			return
+		}
+	}
+}
+
+// Called when the session is closed
+func (s *Session) setCloseHandler(handler func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closeHandler = handler
+}
+
+// Close streams and QUIC connections of all paths
+func (s *Session) teardown() {
+	s.mutex.Lock()
+	s.closed = true
+	streamList := s.streamList
+	connectionList := s.connectionList
+	closeHandler := s.closeHandler
+	s.mutex.Unlock()
 
-	time.Sleep(200 * time.Millisecond)
-	for _, stream := range s.streamList {
+	// Stop connecting new paths
+	s.cancel()
+
+	if closeHandler != nil {
+		closeHandler()
+	}
+
+	for _, stream := range streamList {
+		if stream == nil {
+			continue
+		}
 		stream.Close()
+		// Terminate receiver go routine
+		stream.CancelRead(0)
 	}
+
+	for _, conn := range connectionList {
+		if conn != nil {
+			conn.CloseWithError(0, "")
+		}
+	}
+
+	s.recvBuffer.Close()
 }
@@ -1,163 +1,662 @@
 package multipath
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
 	"fmt"
-	"math/rand"
+	"log"
+	"net"
+	"strconv"
 	"sync"
+	"time"
 
 	quic "github.com/lucas-clemente/quic-go"
 )
 
+const SESSION_ID_RETIRE_TIME = 10 * time.Minute // ID of a removed session is not reassigned during this time
+
 // Session Manager
 type SessionManager struct {
 	mutex          sync.Mutex
+	config         *Config
+	logger         logger
+	tlsConf        *tls.Config // base TLS configuration of paths (see Config.loadTLSConfig())
+	serverTLSConf  *tls.Config // TLS configuration of listeners
 	numPath        int
 	listenerList   []quic.Listener
 	listenAddrList []string
+	nicTypeList    []byte   // type of network interface of each listen address
+	nicIDList      []uint16 // network interface of each listen address (see NicInfo.NicID)
+	nicIDMap       map[string]uint16
+	discoveredList []bool // listen address is discovered from network interfaces (Config.DiscoverNics)
 	sessionMap     map[uint32]*Session
+	retiredIDMap   map[uint32]time.Time // IDs of removed sessions (a stale peer may still use them)
+	connectedList  []*Session           // sessions created by Connect()
 	sessionChan    chan *Session
+	closeChan      chan struct{}       // closed by Close()
+	closedStats    SessionManagerStats // counters of closed sessions and rejected connections (see Stats())
+	closed         bool
 }
 
-func CreateSessionManager(addrList []string) *SessionManager {
+// config is shared by all sessions of SessionManager (nil for default configuration)
+func CreateSessionManager(config *Config) (*SessionManager, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf, err := config.loadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	serverTLSConf, err := serverTLSConfig(tlsConf)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create SessionManager
 	m := SessionManager{
-		numPath:        len(addrList),
-		listenerList:   make([]quic.Listener, len(addrList)),
-		listenAddrList: addrList,
+		config:         config,
+		logger:         config.logger(),
+		tlsConf:        tlsConf,
+		serverTLSConf:  serverTLSConf,
+		numPath:        0,
+		listenerList:   make([]quic.Listener, 0),
+		listenAddrList: make([]string, 0),
+		nicTypeList:    make([]byte, 0),
+		nicIDList:      make([]uint16, 0),
+		nicIDMap:       make(map[string]uint16),
+		discoveredList: make([]bool, 0),
 		sessionMap:     make(map[uint32]*Session),
+		retiredIDMap:   make(map[uint32]time.Time),
+		connectedList:  make([]*Session, 0),
 		sessionChan:    make(chan *Session),
+		closeChan:      make(chan struct{}),
 	}
 
-	m.listen()
+	err = m.listen()
+	if err != nil {
+		return nil, err
+	}
 
-	return &m
-}
+	// Start go routines for all listen addresses
+	for i := 0; i < m.numPath; i++ {
+		go m.accept(m.listenerList[i])
+	}
 
-func (m *SessionManager) listen() {
-	var err error
+	// Detect added or removed interfaces
+	if config.DiscoverNics {
+		go m.monitorNics()
+	}
 
-	// TODO QUIC configuration for enhanced QUIC
-	config := quic.Config{}
+	return &m, nil
+}
 
+func (m *SessionManager) listen() error {
 	// QUIC ListenAddr
-	for i, addr := range m.listenAddrList {
-		Log("ListenAddr[%d]: %s", i, addr)
-		m.listenerList[i], err = quic.ListenAddr(addr, generateTLSConfig(), &config)
+	for i, addr := range m.config.ListenAddrs {
+		// Each configured address is regarded as a distinct interface
+		err := m.addListener(addr, NIC_TYPE_UNKNOWN, m.getNicID(fmt.Sprintf("ListenAddrs[%d]", i)), false)
+		if err != nil {
+			m.closeListeners()
+			return err
+		}
+	}
+
+	if !m.config.DiscoverNics {
+		return nil
+	}
+
+	nics, err := discoverNics()
+	if err != nil {
+		m.closeListeners()
+		return err
+	}
+
+	for _, nic := range nics {
+		addr := net.JoinHostPort(nic.ip.String(), strconv.Itoa(m.config.DiscoverPort))
+		err := m.addListener(addr, nic.nicType, m.getNicID(nic.name), true)
 		if err != nil {
-			panic(err)
+			// Some addresses (e.g. tentative IPv6 addresses) cannot be used yet
+			m.logger.Log("SessionManager.listen(): %s (%s), %v", nic.name, addr, err)
 		}
 	}
+
+	return nil
 }
 
-func (m *SessionManager) Accept() *Session {
-	// Start go routines for all listen addresses
+// Listen on the address and append it to the listen addresses
+// (for a discovered address, the port is assigned by OS if the port of addr is 0)
+func (m *SessionManager) addListener(addr string, nicType byte, nicID uint16, discovered bool) error {
+	// TODO QUIC configuration for enhanced QUIC
+	config := quic.Config{Tracer: quicTracer}
+
+	listener, err := quic.ListenAddr(addr, m.serverTLSConf, &config)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+
+	// Advertise the address assigned by OS (e.g. port 0)
+	if discovered {
+		addr = listener.Addr().String()
+	}
+
+	m.mutex.Lock()
+	m.logger.Log("ListenAddr[%d]: %s (%s)", m.numPath, addr, NicTypeString(nicType))
+	m.listenerList = append(m.listenerList, listener)
+	m.listenAddrList = append(m.listenAddrList, addr)
+	m.nicTypeList = append(m.nicTypeList, nicType)
+	m.nicIDList = append(m.nicIDList, nicID)
+	m.discoveredList = append(m.discoveredList, discovered)
+	m.numPath++
+	m.mutex.Unlock()
+
+	return nil
+}
+
+func (m *SessionManager) closeListeners() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, listener := range m.listenerList {
+		listener.Close()
+	}
+}
+
+// Identifier of a network interface (addresses of the same interface have the same ID)
+func (m *SessionManager) getNicID(name string) uint16 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	nicID, exists := m.nicIDMap[name]
+	if !exists {
+		// 0 is reserved for an unknown interface
+		nicID = uint16(len(m.nicIDMap) + 1)
+		m.nicIDMap[name] = nicID
+	}
+
+	return nicID
+}
+
+func (m *SessionManager) getListenAddrs() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	addrList := make([]string, m.numPath)
+	copy(addrList, m.listenAddrList)
+
+	return addrList
+}
+
+// NIC information of listen addresses advertised to the peer
+func (m *SessionManager) getNicInfos() []NicInfo {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	nicInfos := make([]NicInfo, 0, m.numPath)
 	for i := 0; i < m.numPath; i++ {
-		go m.accept(i, context.Background())
+		nicInfo, err := CreateNicInfo(m.nicTypeList[i], m.nicIDList[i], m.listenAddrList[i])
+		if err != nil {
+			m.logger.Log("SessionManager.getNicInfos(): %v", err)
+			continue
+		}
+		nicInfos = append(nicInfos, nicInfo)
 	}
 
-	// blocking until one session is created
-	sess := <-m.sessionChan
+	return nicInfos
+}
+
+// Listen on addresses of added interfaces and close listeners of removed ones
+// Sessions re-advertise the listen addresses to their peers
+func (m *SessionManager) monitorNics() {
+	for {
+		select {
+		case <-time.After(NIC_MONITOR_INTERVAL):
+		case <-m.closeChan:
+			return
+		}
+
+		nics, err := discoverNics()
+		if err != nil {
+			m.logger.Log("SessionManager.monitorNics(): %v", err)
+			continue
+		}
+
+		// Discovered addresses are identified by IP (the port may be assigned by OS)
+		nicMap := make(map[string]localNic)
+		for _, nic := range nics {
+			nicMap[nic.ip.String()] = nic
+		}
+
+		// Removed interfaces
+		removedAddrList := make([]string, 0)
+		m.mutex.Lock()
+		for i := 0; i < m.numPath; i++ {
+			if !m.discoveredList[i] {
+				continue
+			}
+
+			host, _, _ := net.SplitHostPort(m.listenAddrList[i])
+			if _, exists := nicMap[host]; exists {
+				delete(nicMap, host)
+				continue
+			}
 
-	// Add a new session into session map
-	m.sessionMap[sess.SessionID] = sess
+			m.logger.Log("SessionManager.monitorNics(): Removed address=%s", m.listenAddrList[i])
+			m.listenerList[i].Close()
+			removedAddrList = append(removedAddrList, m.listenAddrList[i])
+
+			m.listenerList = append(m.listenerList[:i], m.listenerList[i+1:]...)
+			m.listenAddrList = append(m.listenAddrList[:i], m.listenAddrList[i+1:]...)
+			m.nicTypeList = append(m.nicTypeList[:i], m.nicTypeList[i+1:]...)
+			m.nicIDList = append(m.nicIDList[:i], m.nicIDList[i+1:]...)
+			m.discoveredList = append(m.discoveredList[:i], m.discoveredList[i+1:]...)
+			m.numPath--
+			i--
+		}
+		m.mutex.Unlock()
 
-	return sess
+		// Added interfaces (remaining in nicMap)
+		addedAddrList := make([]string, 0)
+		for _, nic := range nicMap {
+			addr := net.JoinHostPort(nic.ip.String(), strconv.Itoa(m.config.DiscoverPort))
+			err := m.addListener(addr, nic.nicType, m.getNicID(nic.name), true)
+			if err != nil {
+				m.logger.Log("SessionManager.monitorNics(): %s (%s), %v", nic.name, addr, err)
+				continue
+			}
+
+			m.mutex.Lock()
+			listener := m.listenerList[m.numPath-1]
+			addedAddrList = append(addedAddrList, m.listenAddrList[m.numPath-1])
+			m.mutex.Unlock()
+
+			go m.accept(listener)
+		}
+
+		if len(removedAddrList) == 0 && len(addedAddrList) == 0 {
+			continue
+		}
+
+		m.readvertise(addedAddrList, removedAddrList)
+	}
 }
 
-func (m *SessionManager) accept(pathID int, ctx context.Context) {
+// Update listen addresses of all sessions
+// Peers connect to added addresses, and paths through removed addresses are removed
+func (m *SessionManager) readvertise(addedAddrList []string, removedAddrList []string) {
+	nicInfos := m.getNicInfos()
+
+	m.mutex.Lock()
+	sessionList := make([]*Session, 0, len(m.sessionMap))
+	for _, sess := range m.sessionMap {
+		sessionList = append(sessionList, sess)
+	}
+	m.mutex.Unlock()
+
+	for _, sess := range sessionList {
+		sess.setNicInfos(nicInfos)
 
+		for _, addr := range removedAddrList {
+			sess.removePathsFrom(addr)
+		}
+
+		for _, addr := range addedAddrList {
+			err := sess.sendAddPathPacket(addr)
+			if err != nil {
+				m.logger.Log("SessionManager.readvertise(): SessionID=%d, %v", sess.SessionID, err)
+			}
+		}
+	}
+}
+
+// Accept a new session
+// Blocks until a session is created by a client or ctx is done
+// scheduler is used for transmission of the session (nil for default scheduler)
+func (m *SessionManager) Accept(ctx context.Context, scheduler SessionScheduler) (*Session, error) {
+	select {
+	case sess := <-m.sessionChan:
+		if scheduler != nil {
+			sess.SetScheduler(scheduler)
+		}
+		return sess, nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case <-m.closeChan:
+		return nil, net.ErrClosed
+	}
+}
+
+// Accept QUIC connections of a listener (started once for each listener)
+func (m *SessionManager) accept(listener quic.Listener) {
 	for {
 		// QUIC Accept
-		quicSess, err := m.listenerList[pathID].Accept(ctx)
+		quicSess, err := listener.Accept(context.Background())
 		if err != nil {
-			panic(err)
+			// Listener is closed
+			m.logger.Log("SessionManger.accept(): Listener=%s, %v", listener.Addr().String(), err)
+			return
 		}
 
-		Log("SessionManger.accept(): PathID=%d, Accepted address=%s",
-			pathID, quicSess.RemoteAddr().String())
+		m.logger.Log("SessionManger.accept(): Listener=%s, Accepted address=%s",
+			listener.Addr().String(), quicSess.RemoteAddr().String())
+
+		// Handshake of a client should not block the others
+		go m.handleConnection(quicSess)
+	}
+}
+
+// Add an accepted QUIC connection to a new or existing session
+func (m *SessionManager) handleConnection(quicSess quic.Connection) {
+	// QUIC AcceptStream
+	quicStream, err := quicSess.AcceptStream(context.Background())
+	if err != nil {
+		m.rejectConnection(quicSess, err)
+		return
+	}
+
+	// Receive a Hello Packet
+	hello, err := m.receiveHelloPacket(quicStream)
+	if err != nil {
+		m.rejectConnection(quicSess, err)
+		return
+	}
+	sessionID := hello.SessionID
+
+	// Incompatible peers are rejected before they join a session
+	version, capabilities, err := negotiateProtocol(hello.Version, hello.Capabilities)
+	if err != nil {
+		m.rejectConnection(quicSess, err)
+		return
+	}
+
+	// Listen addresses advertised by a new session
+	nicInfos := m.getNicInfos()
+
+	m.mutex.Lock()
+	var sess *Session
+	isNewSession := (sessionID == 0)
+	if m.closed {
+		m.mutex.Unlock()
+		m.rejectConnection(quicSess, net.ErrClosed)
+		return
+	}
 
-		// QUIC AcceptStream
-		quicStream, err := quicSess.AcceptStream(ctx)
+	if isNewSession {
+		// Assign a new session ID and token (first connection)
+		sessionID, err = m.newSessionID()
 		if err != nil {
-			panic(err)
+			m.mutex.Unlock()
+			m.rejectConnection(quicSess, err)
+			return
+		}
+		token, err := generateToken()
+		if err != nil {
+			m.mutex.Unlock()
+			m.rejectConnection(quicSess, err)
+			return
 		}
 
-		// Receive a Hello Packet
-		sessionID := m.receiveHelloPacket(quicStream)
-
-		m.mutex.Lock()
-		var sess *Session
-		if sessionID == 0 {
-			// Assign a new session ID (first connection)
-			sessionID = rand.Uint32()
+		joinKey, err := deriveJoinKey(quicSess, token)
+		if err != nil {
+			m.mutex.Unlock()
+			m.rejectConnection(quicSess, err)
+			return
+		}
 
-			// Create a new session
-			sess = CreateSession(sessionID, m.listenAddrList)
-			m.sessionMap[sessionID] = sess
-			Log("SessionManager.accept(): New session is created! (SessionID=%d)", sessionID)
+		// Create a new session
+		sess = CreateSession(sessionID, m.config, nil)
+		sess.tlsConf = m.tlsConf
+		sess.token = token
+		sess.joinKey = joinKey
+		sess.setNicInfos(nicInfos)
+		sess.setCloseHandler(func() { m.removeSession(sess) })
+		m.sessionMap[sessionID] = sess
+		m.logger.Log("SessionManager.handleConnection(): New session is created! (SessionID=%d)", sessionID)
+	} else {
+		// Get an existing session
+		var exists bool
+		sess, exists = m.sessionMap[sessionID]
+		if !exists {
+			m.mutex.Unlock()
+			m.rejectConnection(quicSess, fmt.Errorf("%w (SessionID=%d)", ErrSessionNotFound, sessionID))
+			return
+		} else if !sess.checkPathMac(hello.PathMac, quicSess) {
+			m.mutex.Unlock()
+			err = fmt.Errorf("%w (SessionID=%d)", ErrPathAuthFailed, sessionID)
+			log.Printf("SessionManager.handleConnection(): Unauthenticated path from %s is rejected: %v", quicSess.RemoteAddr().String(), err)
+			m.rejectConnection(quicSess, err)
+			return
 		} else {
-			// Get an existing session
-			var exists bool
-			sess, exists = m.sessionMap[sessionID]
-			if !exists {
-				panic(fmt.Sprintf("SessionManger.accept(): Received session ID (%d) is not 0 but not exists in the session map!", sessionID))
-			} else {
-				Log("SessionManager.accept(): New connection is added to existing session! (SessionID=%d)", sessionID)
-			}
+			m.logger.Log("SessionManager.handleConnection(): New connection is added to existing session! (SessionID=%d)", sessionID)
 		}
+	}
 
-		// Add a created session into session map
-		newPathID := sess.AddStream(quicStream, quicSess.RemoteAddr().String())
+	// Client certificate of an additional path should be the same as the first path
+	err = sess.checkPeer(quicSess)
+	if err == nil {
+		err = sess.setProtocol(version, capabilities, hello.MaxPayloadSize)
+	}
+	if err != nil {
 		m.mutex.Unlock()
+		m.rejectConnection(quicSess, fmt.Errorf("%w (SessionID=%d)", err, sessionID))
+		return
+	}
 
-		// Send Hello ACK Packet
-		sess.SendHelloAckPacket(newPathID)
+	// Add a created session into session map
+	newPathID := sess.AddStream(quicSess, quicStream)
+	m.mutex.Unlock()
 
-		// Start a session receiver
-		sess.StartReceiver(newPathID)
+	// Send Hello ACK Packet
+	sess.SendHelloAckPacket(newPathID)
 
-		// Send channel for Accept()
-		m.sessionChan <- sess
+	// Scheduler begins to consider an added path
+	sess.updateSchedulerPaths()
+
+	// Start a session receiver
+	sess.StartReceiver(newPathID)
+
+	// Packets lost with the previous paths are resent (session resumption)
+	if !isNewSession {
+		sess.resume(hello.AckSeqNumber)
+	}
+
+	// Send channel for Accept() only once for each session
+	if isNewSession {
+		select {
+		case m.sessionChan <- sess:
+		case <-m.closeChan:
+		}
 	}
 }
 
-// Receive Hello Packet
-func (s *SessionManager) receiveHelloPacket(quicStream quic.Stream) uint32 {
-	buf := make([]byte, HELLO_PACKET_HEADER_LEN)
+// Assign a session ID which is not used by live or recently removed sessions
+// (mutex should be held by the caller)
+func (m *SessionManager) newSessionID() (uint32, error) {
+	// Forget IDs removed long ago
+	for sessionID, retiredTime := range m.retiredIDMap {
+		if time.Since(retiredTime) >= SESSION_ID_RETIRE_TIME {
+			delete(m.retiredIDMap, sessionID)
+		}
+	}
+
+	buf := make([]byte, 4)
+	for {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		sessionID := binary.BigEndian.Uint32(buf)
+
+		// 0 is reserved for Hello of a new session
+		if sessionID == 0 {
+			continue
+		}
+		if _, exists := m.sessionMap[sessionID]; exists {
+			continue
+		}
+		if _, exists := m.retiredIDMap[sessionID]; exists {
+			continue
+		}
 
+		return sessionID, nil
+	}
+}
+
+// Live sessions (accepted and connected)
+func (m *SessionManager) getSessions() []*Session {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.getSessionsLocked()
+}
+
+// (m.mutex is held by the caller)
+func (m *SessionManager) getSessionsLocked() []*Session {
+	sessionList := make([]*Session, 0, len(m.sessionMap)+len(m.connectedList))
+	for _, sess := range m.sessionMap {
+		sessionList = append(sessionList, sess)
+	}
+	sessionList = append(sessionList, m.connectedList...)
+
+	return sessionList
+}
+
+// Remove a closed session (called by Session.teardown())
+func (m *SessionManager) removeSession(sess *Session) {
+	sessStats := sess.Stats()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	removed := false
+	if m.sessionMap[sess.SessionID] == sess {
+		removed = true
+		delete(m.sessionMap, sess.SessionID)
+		m.retiredIDMap[sess.SessionID] = time.Now()
+		m.logger.Log("SessionManager.removeSession(): SessionID=%d is removed", sess.SessionID)
+	}
+
+	for i, connected := range m.connectedList {
+		if connected == sess {
+			removed = true
+			m.connectedList = append(m.connectedList[:i], m.connectedList[i+1:]...)
+			break
+		}
+	}
+
+	// Counters of the session are kept (only once for each session)
+	if removed {
+		m.closedStats.add(sessStats)
+	}
+}
+
+// Close a QUIC connection which cannot be added to a session
+func (m *SessionManager) rejectConnection(quicSess quic.Connection, err error) {
+	m.mutex.Lock()
+	m.closedStats.HandshakeFailures++
+	m.mutex.Unlock()
+
+	m.logger.Log("SessionManager.handleConnection(): Address=%s, %v", quicSess.RemoteAddr().String(), err)
+	quicSess.CloseWithError(QUIC_ERROR_HANDSHAKE_FAILED, err.Error())
+}
+
+// Receive Hello Packet
+func (s *SessionManager) receiveHelloPacket(quicStream quic.Stream) (*HelloPacket, error) {
 	// Read Hello Packet from quic stream
-	_, err := quicStream.Read(buf[:HELLO_PACKET_HEADER_LEN])
+	received, err := ReadPacket(quicStream, s.config.PacketSize)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	// Parse packet type
-	r := bytes.NewReader(buf[:HELLO_PACKET_HEADER_LEN])
-	packetType, _ := r.ReadByte()
-
 	// Parse packet
-	if packetType == HELLO_PACKET {
-		reader := bytes.NewReader(buf)
-		packet, err := ParseHelloPacket(reader)
-		if err != nil {
-			panic(err)
-		}
-		Log("SessionManager.receiveHelloPacket(): SessionID=%d", packet.SessionID)
+	if packet, ok := received.(*HelloPacket); ok {
+		s.logger.Log("SessionManager.receiveHelloPacket(): SessionID=%d, Version=%d, Capabilities=0x%x, AckSeq=%d", packet.SessionID, packet.Version, packet.Capabilities, packet.AckSeqNumber)
 
-		return packet.SessionID
+		return packet, nil
 	} else {
-		panic(fmt.Sprintf("SessionManager.receiveHelloPacket(): Unknown initial packet type (%d)", packetType))
+		return nil, fmt.Errorf("%w: initial packet type (%d) is not Hello", ErrHandshakeFailed, received.GetType())
 	}
 }
 
 // Connect
-func (m *SessionManager) Connect(addr string) *Session {
+// scheduler is used for transmission of the session (nil for default scheduler)
+func (m *SessionManager) Connect(addr string, scheduler SessionScheduler) (*Session, error) {
+	return m.connectContext(context.Background(), addr, scheduler)
+}
+
+// ctx bounds the handshake of the first path (see DialContext())
+func (m *SessionManager) connectContext(ctx context.Context, addr string, scheduler SessionScheduler) (*Session, error) {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return nil, net.ErrClosed
+	}
+	m.mutex.Unlock()
+
 	// Create Session
-	sess := CreateSession(0, m.listenAddrList)
+	sess := CreateSession(0, m.config, scheduler)
+	sess.tlsConf = m.tlsConf
+
+	err := sess.connectContext(ctx, addr)
+	if err != nil {
+		sess.teardown()
+
+		// Session is not added yet, so its handshake failures are counted here
+		sessStats := sess.Stats()
+		m.mutex.Lock()
+		m.closedStats.add(sessStats)
+		m.mutex.Unlock()
+
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	m.connectedList = append(m.connectedList, sess)
+	m.mutex.Unlock()
+	sess.setCloseHandler(func() { m.removeSession(sess) })
+
+	return sess, nil
+}
+
+// Close all listeners and sessions
+// Sessions are closed gracefully in parallel (see Session.Close())
+func (m *SessionManager) Close() error {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return net.ErrClosed
+	}
+	m.closed = true
+	close(m.closeChan)
+	m.mutex.Unlock()
+
+	sessionList := m.getSessions()
+
+	// Connections of a listener share its UDP socket,
+	// so listeners are closed after sessions (new connections are rejected meanwhile)
+	var wg sync.WaitGroup
+	for _, sess := range sessionList {
+		wg.Add(1)
+		go func(sess *Session) {
+			defer wg.Done()
+			err := sess.Close()
+			if err != nil {
+				m.logger.Log("SessionManager.Close(): SessionID=%d, %v", sess.SessionID, err)
+			}
+		}(sess)
+	}
+	wg.Wait()
 
-	sess.Connect(addr)
+	m.closeListeners()
 
-	return sess
+	return nil
 }
@@ -1,92 +1,132 @@
 package multipath
 
+import (
+	"sync"
+	"time"
+)
+
 const (
-	SCHED_USER_WRR = 1 // User-defined weight round robin
-	SCHED_NET_WRR  = 2 // Newtwork condition based weight round robin
+	SCHED_USER_WRR    = 1 // User-defined weight round robin
+	SCHED_NET_WRR     = 2 // Newtwork condition based weight round robin
+	SCHED_LOWEST_RTT  = 3 // Lowest RTT first
+	SCHED_ROUND_ROBIN = 4 // Round robin
+	SCHED_REDUNDANT   = 5 // Send every packet through all paths
 )
 
-const REMAINING_BYTES_RESET_THRESH = DATA_PACKET_PAYLOAD_SIZE / 8
+const SCHED_RTT_ALPHA = 0.125 // EWMA gain for smoothed RTT
 
 // Multipath session scheduler for packet transmission
-type SessionScheduler struct {
-	schedulerType  int
-	numPath        int
-	weight         []uint32
-	remainingBytes []uint32
-	currentPath    int
-}
+type SessionScheduler interface {
+	// Let scheduler consider paths from 0 to numPath-1
+	SetNumPath(numPath int)
 
-func CreateSessionScheduler(schedType int) *SessionScheduler {
-	c := SessionScheduler{
-		schedulerType:  schedType,
-		numPath:        0,
-		remainingBytes: make([]uint32, 0),
-	}
+	// Exclude a failed or removed path from scheduling (or include it again)
+	// Path IDs are not reused, so a removed path stays excluded and the remaining paths are rebalanced
+	SetPathAvailable(pathID int, available bool)
 
-	// Set weight
-	if schedType == SCHED_USER_WRR {
-		c.weight = make([]uint32, len(CONFIG_USER_WRR_WEIGHT))
-		for i := 0; i < len(c.weight); i++ {
-			c.weight[i] = CONFIG_USER_WRR_WEIGHT[i]
-		}
-	}
+	// Update network condition of the path when a data packet sent through the path is acknowledged
+	UpdatePathCondition(pathID int, rtt time.Duration, ackedBytes uint32)
 
-	return &c
+	// Select paths to send a packet (empty if there is no available path)
+	Scheduling(payloadSize uint32) []int
 }
 
-func (c *SessionScheduler) SetNumPath(numPath int) {
-	c.numPath = numPath
+// Optionally implemented by schedulers which distribute packets by weight (see Session.Stats())
+type WeightedScheduler interface {
+	// Current weight of each path (0 for an unavailable path)
+	GetWeights() []uint32
+}
 
-	if c.numPath > 1 {
-		// when the additional path is added,
-		// reset remaining bytes of current path
-		c.remainingBytes[c.currentPath] = c.weight[c.currentPath] * DATA_PACKET_PAYLOAD_SIZE
-	}
+// Create a scheduler with default parameters (see Config.CreateScheduler() for configured ones)
+func CreateSessionScheduler(schedType int) SessionScheduler {
+	switch schedType {
+	case SCHED_USER_WRR, SCHED_NET_WRR:
+		return CreateWrrScheduler(schedType, DEFAULT_USER_WRR_WEIGHT, DATA_PACKET_PAYLOAD_SIZE)
+
+	case SCHED_LOWEST_RTT:
+		return CreateLowestRttScheduler()
+
+	case SCHED_ROUND_ROBIN:
+		return CreateRoundRobinScheduler()
+
+	case SCHED_REDUNDANT:
+		return CreateRedundantScheduler()
 
-	// change current path to new path and set the remainig bytes
-	c.currentPath = c.numPath - 1
-	remainBytesOfNewPath := c.weight[c.currentPath] * DATA_PACKET_PAYLOAD_SIZE
+	default:
+		return CreateWrrScheduler(SCHED_USER_WRR, DEFAULT_USER_WRR_WEIGHT, DATA_PACKET_PAYLOAD_SIZE)
+	}
+}
 
-	c.remainingBytes = append(c.remainingBytes, remainBytesOfNewPath)
+// Path state shared by schedulers
+// (methods assume that mutex is held by the caller)
+type schedulerBase struct {
+	mutex         sync.Mutex
+	numPath       int
+	pathAvailable []bool
+	smoothedRTT   []time.Duration
+	logger        logger // logger of the session (see setSchedulerLogger())
+}
 
-	Log("SetNumPath=%d, len remainingBytes=%d", numPath, len(c.remainingBytes))
+// Built-in schedulers log with the logger of their session (other schedulers are not affected)
+func setSchedulerLogger(scheduler SessionScheduler, l logger) {
+	if base, ok := scheduler.(interface{ setLogger(l logger) }); ok {
+		base.setLogger(l)
+	}
 }
 
-// Weighted Round robin
-func (c *SessionScheduler) Scheduling(payloadSize uint32) int {
-	pathID := 0
+func (c *schedulerBase) setLogger(l logger) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	switch c.schedulerType {
-	case SCHED_USER_WRR:
-		pathID = c.scheduling_user_wrr(payloadSize)
+	c.logger = l
+}
 
-	case SCHED_NET_WRR:
-		pathID = c.scheduling_net_wrr(payloadSize)
+func (c *schedulerBase) setNumPath(numPath int) {
+	c.numPath = numPath
 
-	default:
-		pathID = c.scheduling_user_wrr(payloadSize)
+	for len(c.pathAvailable) < c.numPath {
+		c.pathAvailable = append(c.pathAvailable, true)
+		c.smoothedRTT = append(c.smoothedRTT, 0)
 	}
-
-	return pathID
 }
 
-// User-defined weight round robin
-func (c *SessionScheduler) scheduling_user_wrr(payloadSize uint32) int {
-	selectedPath := c.currentPath
+func (c *schedulerBase) setPathAvailable(pathID int, available bool) bool {
+	// The path is not yet considered by scheduler
+	if pathID >= len(c.pathAvailable) {
+		return false
+	}
+
+	c.pathAvailable[pathID] = available
 
-	// update remaing bytes and sent bytes of selected path
-	c.remainingBytes[selectedPath] -= payloadSize
+	c.logger.Log("SessionScheduler.SetPathAvailable(): PathID=%d, Available=%t", pathID, available)
 
-	// reset remaining bytes of selected path and change the current path to next path
-	if c.remainingBytes[selectedPath] <= REMAINING_BYTES_RESET_THRESH {
-		c.remainingBytes[selectedPath] = c.weight[selectedPath] * DATA_PACKET_PAYLOAD_SIZE
-		c.currentPath = (c.currentPath + 1) % c.numPath
+	return true
+}
+
+// Smoothed RTT (RFC 6298)
+func (c *schedulerBase) updateRTT(pathID int, rtt time.Duration) bool {
+	// The path is not yet considered by scheduler
+	if pathID >= len(c.pathAvailable) {
+		return false
+	}
+
+	if c.smoothedRTT[pathID] == 0 {
+		c.smoothedRTT[pathID] = rtt
+	} else {
+		c.smoothedRTT[pathID] = time.Duration((1-SCHED_RTT_ALPHA)*float64(c.smoothedRTT[pathID]) + SCHED_RTT_ALPHA*float64(rtt))
 	}
 
-	return selectedPath
+	return true
 }
 
-// TODO need network information
-func (c *SessionScheduler) scheduling_net_wrr(payloadSize uint32) int {
-	return 0
+// Find the next available path after the given path (-1 if no path is available)
+func (c *schedulerBase) nextAvailablePath(pathID int) int {
+	for i := 1; i <= c.numPath; i++ {
+		next := (pathID + i) % c.numPath
+		if c.pathAvailable[next] {
+			return next
+		}
+	}
+
+	return -1
 }
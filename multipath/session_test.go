@@ -0,0 +1,59 @@
+package multipath
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitUntil(ctx context.Context, t *testing.T, message string, condition func() bool) {
+	for !condition() {
+		select {
+		case <-ctx.Done():
+			t.Fatal(message)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Path which fails after Goodbye of the peer is still failed (its unacknowledged packets are reinjected)
+func TestPathFailureAfterGoodbye(t *testing.T) {
+	server := createTestSessionManager(t, func(c *Config) { c.ListenAddrs = []string{"127.0.0.1:0", "127.0.0.1:0"} })
+	client := createTestSessionManager(t, func(c *Config) { c.TLSInsecureSkipVerify = true })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clientSess, err := client.Connect(server.listenerList[0].Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientSess.Close()
+
+	serverSess, err := server.Accept(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = clientSess.AddPath("", server.listenerList[1].Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(ctx, t, "second path is not started", func() bool { return len(serverSess.PathIDs()) == 2 })
+
+	// Goodbye whose data packets are not received yet
+	serverSess.handleGoodbyePacket(CreateGoodbyePacket(serverSess.SessionID, 100), 0)
+	if serverSess.isClosed() || serverSess.isPeerDone() {
+		t.Fatal("session is closed before the data of the peer is received")
+	}
+
+	clientSess.mutex.Lock()
+	conn := clientSess.connectionList[1]
+	clientSess.mutex.Unlock()
+	conn.CloseWithError(0, "")
+
+	waitUntil(ctx, t, "path is not failed after Goodbye", func() bool {
+		_, status := serverSess.getStream(1)
+		return status != PATH_ACTIVE
+	})
+}
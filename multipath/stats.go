@@ -0,0 +1,169 @@
+package multipath
+
+import (
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// Statistics of a path (see Session.Stats())
+type PathStats struct {
+	PathID            int
+	Status            int // PATH_*
+	LocalAddr         string
+	RemoteAddr        string
+	SentBytes         uint64 // payload bytes of data packets
+	RecvBytes         uint64
+	SentPackets       uint64 // data packets (including reinjected ones)
+	RecvPackets       uint64
+	ReinjectedPackets uint64        // data packets reinjected from the path into another path
+	LostPackets       uint64        // QUIC packets declared lost (retransmitted by QUIC)
+	RTT               time.Duration // smoothed RTT measured by QUIC
+	MinRTT            time.Duration
+	PingRTT           time.Duration // smoothed RTT measured by keepalive probes
+	Cwnd              uint64        // congestion window of QUIC (bytes)
+	BytesInFlight     uint64
+	Weight            uint32 // weight of the path for weighted schedulers (0 for the others)
+}
+
+// Statistics of a session
+type SessionStats struct {
+	SessionID           uint32
+	Uptime              time.Duration
+	NumActivePath       int
+	SentBytes           uint64 // sum of all paths
+	RecvBytes           uint64
+	SentPackets         uint64
+	RecvPackets         uint64
+	ReinjectedPackets   uint64
+	ReorderedPackets    uint64 // data packets received out of order
+	ReorderBufferLen    int    // packets waiting for previous packets
+	MaxReorderBufferLen int
+	UnackedPackets      int // data packets not yet acknowledged by the peer
+	HandshakeFailures   uint64
+	PathUps             uint64 // path up events (see PathEvent)
+	PathDowns           uint64
+	Paths               []PathStats
+}
+
+// Aggregate statistics of all sessions of a session manager (see SessionManager.Stats())
+// Counters include closed sessions, so they never decrease
+type SessionManagerStats struct {
+	NumSession        int // live sessions (accepted and connected)
+	NumActivePath     int
+	ReorderBufferLen  int
+	SentBytes         uint64
+	RecvBytes         uint64
+	SentPackets       uint64
+	RecvPackets       uint64
+	ReinjectedPackets uint64
+	ReorderedPackets  uint64
+	HandshakeFailures uint64 // including connections rejected by the session manager
+	PathUps           uint64
+	PathDowns         uint64
+	Sessions          []SessionStats // live sessions
+}
+
+// Statistics of the session and its paths
+func (s *Session) Stats() SessionStats {
+	s.mutex.Lock()
+	stats := SessionStats{
+		SessionID: s.SessionID,
+		Uptime:    time.Since(s.startTime),
+		Paths:     make([]PathStats, s.numPath),
+	}
+	connectionList := make([]quic.Connection, s.numPath)
+	copy(connectionList, s.connectionList)
+	for i := 0; i < s.numPath; i++ {
+		stats.Paths[i] = PathStats{
+			PathID:            i,
+			Status:            s.pathStatusList[i],
+			LocalAddr:         s.localAddrList[i],
+			RemoteAddr:        s.connectedAddrList[i],
+			SentBytes:         s.sentBytes[i],
+			RecvBytes:         s.recvBytes[i],
+			SentPackets:       s.sentPackets[i],
+			RecvPackets:       s.recvPackets[i],
+			ReinjectedPackets: s.reinjectedPackets[i],
+			PingRTT:           s.rttList[i],
+		}
+	}
+	stats.HandshakeFailures = s.handshakeFailures
+	stats.PathUps, stats.PathDowns = s.pathUps, s.pathDowns
+	s.mutex.Unlock()
+
+	var weights []uint32
+	if scheduler, ok := s.getScheduler().(WeightedScheduler); ok {
+		weights = scheduler.GetWeights()
+	}
+
+	for i := range stats.Paths {
+		path := &stats.Paths[i]
+
+		// Connection of a closed path is released
+		var metrics quicMetrics
+		if connectionList[i] != nil {
+			metrics = quicTracer.getMetrics(connectionList[i])
+		}
+		path.LostPackets = metrics.lostPackets
+		path.RTT = metrics.smoothedRTT
+		path.MinRTT = metrics.minRTT
+		path.Cwnd = metrics.cwnd
+		path.BytesInFlight = metrics.bytesInFlight
+
+		if i < len(weights) {
+			path.Weight = weights[i]
+		}
+
+		if path.Status == PATH_ACTIVE {
+			stats.NumActivePath++
+		}
+		stats.SentBytes += path.SentBytes
+		stats.RecvBytes += path.RecvBytes
+		stats.SentPackets += path.SentPackets
+		stats.RecvPackets += path.RecvPackets
+		stats.ReinjectedPackets += path.ReinjectedPackets
+	}
+
+	stats.ReorderBufferLen, stats.MaxReorderBufferLen, stats.ReorderedPackets = s.recvBuffer.GetReorderStats()
+	stats.UnackedPackets = s.sendBuffer.GetLength()
+
+	return stats
+}
+
+// Statistics of the live sessions plus the totals of closed sessions
+func (m *SessionManager) Stats() SessionManagerStats {
+	// Live sessions and counters of closed sessions are taken at once,
+	// so that a session removed meanwhile is not counted twice
+	m.mutex.Lock()
+	sessionList := m.getSessionsLocked()
+	stats := m.closedStats
+	m.mutex.Unlock()
+
+	stats.NumSession = len(sessionList)
+	stats.Sessions = make([]SessionStats, 0, len(sessionList))
+
+	for _, sess := range sessionList {
+		sessStats := sess.Stats()
+
+		stats.add(sessStats)
+		stats.NumActivePath += sessStats.NumActivePath
+		stats.ReorderBufferLen += sessStats.ReorderBufferLen
+		stats.Sessions = append(stats.Sessions, sessStats)
+	}
+
+	return stats
+}
+
+// Add counters of a session
+func (m *SessionManagerStats) add(sessStats SessionStats) {
+	m.SentBytes += sessStats.SentBytes
+	m.RecvBytes += sessStats.RecvBytes
+	m.SentPackets += sessStats.SentPackets
+	m.RecvPackets += sessStats.RecvPackets
+	m.ReinjectedPackets += sessStats.ReinjectedPackets
+	m.ReorderedPackets += sessStats.ReorderedPackets
+	m.HandshakeFailures += sessStats.HandshakeFailures
+	m.PathUps += sessStats.PathUps
+	m.PathDowns += sessStats.PathDowns
+}
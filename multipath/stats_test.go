@@ -0,0 +1,23 @@
+package multipath
+
+import (
+	"testing"
+)
+
+// Handshake failures of a session which is not connected are counted by the session manager
+func TestStatsConnectFailure(t *testing.T) {
+	server := createTestSessionManager(t, func(c *Config) {})
+	client := createTestSessionManager(t, func(c *Config) {})
+
+	// Self-signed certificate of the acceptor is not verified
+	sess, err := client.Connect(server.listenerList[0].Addr().String(), nil)
+	if err == nil {
+		sess.Close()
+		t.Fatal("connected without verifying the acceptor")
+	}
+
+	stats := client.Stats()
+	if stats.HandshakeFailures != 1 || stats.NumSession != 0 {
+		t.Fatalf("%d handshake failures and %d sessions after a failed connect", stats.HandshakeFailures, stats.NumSession)
+	}
+}
@@ -0,0 +1,178 @@
+package multipath
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+const QUIC_ALPN = "mp2bs" // ALPN protocol of paths (protocol version is negotiated by Hello)
+
+// Labels of TLS exporters (RFC 5705)
+const (
+	EXPORTER_LABEL_SESSION = "EXPORTER-mp2bs-session" // join key of a session (first path)
+	EXPORTER_LABEL_PATH    = "EXPORTER-mp2bs-path"    // MAC of an additional path
+)
+
+const JOIN_KEY_LEN = 32
+
+// Base TLS configuration of all paths (Config.TLSConfig or certificate files)
+// Certificates are used by both sides, RootCAs by the initiator and ClientCAs by the acceptor
+func (c *Config) loadTLSConfig() (*tls.Config, error) {
+	if c.TLSConfig != nil {
+		return c.TLSConfig.Clone(), nil
+	}
+
+	tlsConf := &tls.Config{ServerName: c.TLSServerName}
+
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("load TLS CA: no certificate in %s", c.TLSCAFile)
+		}
+		tlsConf.RootCAs = pool
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if c.TLSRequireClientCert {
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	// Verification is skipped only if explicitly configured (e.g. self-signed certificate of the acceptor)
+	// System roots are used if CA is not configured
+	if c.TLSInsecureSkipVerify {
+		log.Printf("Config.loadTLSConfig(): Certificate of the acceptor is not verified (tls_insecure_skip_verify)")
+		tlsConf.InsecureSkipVerify = true
+	}
+
+	return tlsConf, nil
+}
+
+// TLS configuration of listeners
+// A self-signed certificate is generated if no certificate is configured
+func serverTLSConfig(base *tls.Config) (*tls.Config, error) {
+	tlsConf := base.Clone()
+	tlsConf.NextProtos = []string{QUIC_ALPN}
+
+	if len(tlsConf.Certificates) == 0 && tlsConf.GetCertificate == nil {
+		cert, err := generateCertificate()
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConf, nil
+}
+
+// TLS configuration of a new path to the acceptor
+// All paths verify serverName (the acceptor is connected through its advertised IPs)
+func clientTLSConfig(base *tls.Config, serverName string) *tls.Config {
+	tlsConf := base.Clone()
+	tlsConf.NextProtos = []string{QUIC_ALPN}
+
+	if tlsConf.ServerName == "" {
+		tlsConf.ServerName = serverName
+	}
+
+	return tlsConf
+}
+
+// Generate a self-signed certificate (ECDSA P-256)
+func generateCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{certDER}, PrivateKey: key}, nil
+}
+
+// Verified certificate chain of the peer of a QUIC connection (nil if the peer is not verified)
+func verifiedChain(conn quic.Connection) []*x509.Certificate {
+	chains := conn.ConnectionState().TLS.VerifiedChains
+	if len(chains) == 0 {
+		return nil
+	}
+	return chains[0]
+}
+
+// Paths of a session are connected to the same peer if their leaf certificates are equal
+func samePeer(chain1 []*x509.Certificate, chain2 []*x509.Certificate) bool {
+	if len(chain1) == 0 || len(chain2) == 0 {
+		return len(chain1) == len(chain2)
+	}
+	return bytes.Equal(chain1[0].Raw, chain2[0].Raw)
+}
+
+// Key authenticating additional paths of a session
+// Both sides export it from the TLS connection of the first path and the token issued by Hello ACK,
+// so that the token alone cannot join the session
+func deriveJoinKey(conn quic.Connection, token [RESUMPTION_TOKEN_LEN]byte) ([]byte, error) {
+	state := conn.ConnectionState()
+	return state.TLS.ExportKeyingMaterial(EXPORTER_LABEL_SESSION, token[:], JOIN_KEY_LEN)
+}
+
+// MAC of Hello of an additional path
+// The MAC is bound to the TLS connection of the path, so it cannot be replayed on another connection
+func computePathMac(joinKey []byte, sessionID uint32, conn quic.Connection) ([PATH_MAC_LEN]byte, error) {
+	var pathMac [PATH_MAC_LEN]byte
+
+	state := conn.ConnectionState()
+	ekm, err := state.TLS.ExportKeyingMaterial(EXPORTER_LABEL_PATH, nil, JOIN_KEY_LEN)
+	if err != nil {
+		return pathMac, err
+	}
+
+	b := &bytes.Buffer{}
+	WriteUint32(b, sessionID)
+	b.Write(ekm)
+
+	mac := hmac.New(sha256.New, joinKey)
+	mac.Write(b.Bytes())
+	copy(pathMac[:], mac.Sum(nil))
+
+	return pathMac, nil
+}
@@ -0,0 +1,57 @@
+package multipath
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createTestSessionManager(t *testing.T, configure func(c *Config)) *SessionManager {
+	config := DefaultConfig()
+	config.ListenAddrs = []string{"127.0.0.1:0"}
+	config.Verbose = false
+	config.AutoConnect = false
+	configure(config)
+
+	m, err := CreateSessionManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	return m
+}
+
+// Self-signed certificate of the acceptor is accepted only with TLSInsecureSkipVerify
+func TestTLSInsecureSkipVerify(t *testing.T) {
+	server := createTestSessionManager(t, func(c *Config) {})
+	serverAddr := server.listenerList[0].Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go server.Accept(ctx, nil)
+
+	client := createTestSessionManager(t, func(c *Config) {})
+	sess, err := client.Connect(serverAddr, nil)
+	if err == nil {
+		sess.Close()
+		t.Fatal("self-signed certificate is accepted without tls_insecure_skip_verify")
+	}
+
+	insecureClient := createTestSessionManager(t, func(c *Config) { c.TLSInsecureSkipVerify = true })
+	sess, err = insecureClient.Connect(serverAddr, nil)
+	if err != nil {
+		t.Fatalf("tls_insecure_skip_verify: %v", err)
+	}
+	sess.Close()
+}
+
+func TestTLSInsecureSkipVerifyConflict(t *testing.T) {
+	config := DefaultConfig()
+	config.TLSInsecureSkipVerify = true
+	config.TLSServerName = "mpserver"
+
+	if err := config.Validate(); err == nil {
+		t.Error("tls_insecure_skip_verify is accepted with tls_server_name")
+	}
+}
@@ -2,14 +2,11 @@ package multipath
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
-	"crypto/rsa"
-	"crypto/tls"
-	"crypto/x509"
-	"encoding/pem"
 	"fmt"
 	"io"
-	"math/big"
+	"net"
 	"time"
 )
 
@@ -63,33 +60,43 @@ func WriteUint16(w *bytes.Buffer, i uint16) {
 	w.Write([]byte{uint8(i >> 8), uint8(i)})
 }
 
-// Generate TLS Configuration
-func generateTLSConfig() *tls.Config {
-	key, err := rsa.GenerateKey(rand.Reader, 1024)
-	if err != nil {
-		panic(err)
-	}
-	template := x509.Certificate{SerialNumber: big.NewInt(1)}
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
-	if err != nil {
-		panic(err)
-	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
-	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+// Sequence numbers of data packets wrap around after 2^32 packets (serial number arithmetic, RFC 1982)
+// a is before b if b is ahead of a by less than 2^31, which holds for all packets in flight and reordered
+func seqBefore(a uint32, b uint32) bool {
+	return int32(a-b) < 0
+}
 
-	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		panic(err)
-	}
-	return &tls.Config{
-		Certificates: []tls.Certificate{tlsCert},
-		NextProtos:   []string{"socket-programming"},
+// Random token which authenticates additional paths and resumption of a session
+func generateToken() ([RESUMPTION_TOKEN_LEN]byte, error) {
+	var token [RESUMPTION_TOKEN_LEN]byte
+	_, err := rand.Read(token[:])
+	return token, err
+}
+
+// Open a UDP socket bound to the local address (and the network interface if device is not empty)
+func listenUDP(localAddr string, device string) (net.PacketConn, error) {
+	config := net.ListenConfig{}
+	if device != "" {
+		config.Control = bindToDevice(device)
 	}
+
+	return config.ListenPacket(context.Background(), "udp", localAddr)
 }
 
+// Print a log message with a timestamp
 func Log(format string, args ...interface{}) {
-	if verbose_mode {
-		pre := "[" + time.Now().Format(time.StampMicro) + "] "
-		fmt.Printf(pre+format+"\n", args...)
+	pre := "[" + time.Now().Format(time.StampMicro) + "] "
+	fmt.Printf(pre+format+"\n", args...)
+}
+
+// Logger of a session manager and its sessions, printing only in verbose mode (see Config.Verbose)
+// Each manager has its own logger, so managers in a process can use different settings
+type logger struct {
+	verbose bool
+}
+
+func (l logger) Log(format string, args ...interface{}) {
+	if l.verbose {
+		Log(format, args...)
 	}
 }
@@ -0,0 +1,55 @@
+package multipath
+
+import (
+	"fmt"
+)
+
+// Protocol version in Hello and Hello ACK
+// Both sides use the lower version of them, so peers can be upgraded one by one
+const (
+	PROTOCOL_VERSION     = 1 // latest version of this implementation
+	MIN_PROTOCOL_VERSION = 1 // oldest version of peers which this implementation can speak
+)
+
+// Capabilities in Hello and Hello ACK (bitmap)
+// Capabilities supported by both sides are enabled
+const (
+	CAP_ACK         = 1 << 0 // data packets are acknowledged by ACK packets
+	CAP_KEEPALIVE   = 1 << 1 // PING is answered by PONG (see Config.PingIntervalMs)
+	CAP_COMPRESSION = 1 << 2 // reserved
+	CAP_FEC         = 1 << 3 // reserved
+)
+
+const LOCAL_CAPABILITIES = CAP_ACK | CAP_KEEPALIVE // capabilities of this implementation
+const REQUIRED_CAPABILITIES = CAP_ACK              // peers without them are rejected
+
+// Common protocol version and capabilities with the peer (error if the peer is incompatible)
+func negotiateProtocol(peerVersion byte, peerCapabilities uint32) (byte, uint32, error) {
+	version, err := negotiateVersion(peerVersion)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if peerCapabilities&REQUIRED_CAPABILITIES != REQUIRED_CAPABILITIES {
+		return 0, 0, fmt.Errorf("%w: required capabilities (0x%x) are not supported by the peer (0x%x)", ErrIncompatiblePeer, REQUIRED_CAPABILITIES, peerCapabilities)
+	}
+
+	return version, peerCapabilities & LOCAL_CAPABILITIES, nil
+}
+
+// Common protocol version with the peer
+func negotiateVersion(peerVersion byte) (byte, error) {
+	if peerVersion < MIN_PROTOCOL_VERSION {
+		return 0, fmt.Errorf("%w: version %d of the peer is older than %d", ErrIncompatiblePeer, peerVersion, MIN_PROTOCOL_VERSION)
+	}
+
+	if peerVersion < PROTOCOL_VERSION {
+		return peerVersion, nil
+	}
+	return PROTOCOL_VERSION, nil
+}
+
+// Payload size of data packets which this side can receive (advertised to the peer)
+func maxRecvPayloadSize(config *Config) uint16 {
+	return uint16(config.PacketSize - DATA_PACKET_HEADER_LEN)
+}
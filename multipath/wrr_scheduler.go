@@ -0,0 +1,290 @@
+package multipath
+
+import (
+	"math"
+	"time"
+)
+
+const NET_WRR_MAX_WEIGHT = 10                          // weight of the best path
+const NET_WRR_UPDATE_INTERVAL = 100 * time.Millisecond // interval for re-balancing weights
+const NET_WRR_RATE_ALPHA = 0.125                       // EWMA gain for delivery rate
+
+// Weighted round robin scheduler (SCHED_USER_WRR, SCHED_NET_WRR)
+type WrrScheduler struct {
+	schedulerBase
+	schedulerType  int
+	payloadSize    uint32
+	weight         []uint32
+	remainingBytes []uint32
+	currentPath    int
+
+	// Network condition of each path (measured by ACKs)
+	deliveryRate  []float64 // bytes per second
+	ackedBytes    []uint32  // acknowledged bytes since last weight update
+	inflightBytes []uint32  // scheduled but not yet acknowledged bytes
+	lastUpdate    time.Time
+}
+
+// weight is used by SCHED_USER_WRR (weight[i] * payloadSize bytes are sent through path i in turn)
+func CreateWrrScheduler(schedType int, weight []uint32, payloadSize int) *WrrScheduler {
+	c := WrrScheduler{
+		schedulerType:  schedType,
+		payloadSize:    uint32(payloadSize),
+		weight:         make([]uint32, 0),
+		remainingBytes: make([]uint32, 0),
+		deliveryRate:   make([]float64, 0),
+		ackedBytes:     make([]uint32, 0),
+		inflightBytes:  make([]uint32, 0),
+		lastUpdate:     time.Now(),
+	}
+
+	// Set weight
+	if schedType == SCHED_USER_WRR {
+		c.weight = make([]uint32, len(weight))
+		copy(c.weight, weight)
+	}
+
+	return &c
+}
+
+func (c *WrrScheduler) SetNumPath(numPath int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setNumPath(numPath)
+
+	// New path begins with the highest weight until its network condition is measured
+	// (also for user-defined weights when there are more paths than configured weights)
+	for len(c.weight) < c.numPath {
+		if c.schedulerType == SCHED_NET_WRR {
+			c.weight = append(c.weight, NET_WRR_MAX_WEIGHT)
+		} else {
+			c.weight = append(c.weight, 1)
+		}
+	}
+
+	// Several paths may be added at once (e.g. scheduler is set after additional paths have joined)
+	for len(c.remainingBytes) < c.numPath {
+		c.remainingBytes = append(c.remainingBytes, c.weight[len(c.remainingBytes)]*c.payloadSize)
+		c.deliveryRate = append(c.deliveryRate, 0)
+		c.ackedBytes = append(c.ackedBytes, 0)
+		c.inflightBytes = append(c.inflightBytes, 0)
+	}
+
+	if c.numPath > 1 {
+		// when the additional path is added,
+		// reset remaining bytes of current path
+		c.remainingBytes[c.currentPath] = c.weight[c.currentPath] * c.payloadSize
+	}
+
+	// change current path to new path
+	c.currentPath = c.numPath - 1
+
+	c.logger.Log("SetNumPath=%d, len remainingBytes=%d", numPath, len(c.remainingBytes))
+}
+
+func (c *WrrScheduler) SetPathAvailable(pathID int, available bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.setPathAvailable(pathID, available) {
+		return
+	}
+
+	if !available {
+		c.inflightBytes[pathID] = 0
+	}
+
+	c.rebalance()
+}
+
+// Weight of each path (0 for an unavailable path)
+func (c *WrrScheduler) GetWeights() []uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	weights := make([]uint32, c.numPath)
+	for i := 0; i < c.numPath; i++ {
+		if c.pathAvailable[i] {
+			weights[i] = c.weight[i]
+		}
+	}
+
+	return weights
+}
+
+// Start a new round with the available paths (e.g. when a path is removed)
+func (c *WrrScheduler) rebalance() {
+	if c.schedulerType == SCHED_NET_WRR {
+		// The best available path gets the highest weight
+		maxWeight := uint32(0)
+		for i := 0; i < c.numPath; i++ {
+			if c.pathAvailable[i] && c.weight[i] > maxWeight {
+				maxWeight = c.weight[i]
+			}
+		}
+
+		if maxWeight > 0 && maxWeight < NET_WRR_MAX_WEIGHT {
+			for i := 0; i < c.numPath; i++ {
+				weight := uint32(math.Round(float64(NET_WRR_MAX_WEIGHT*c.weight[i]) / float64(maxWeight)))
+				if weight > NET_WRR_MAX_WEIGHT {
+					weight = NET_WRR_MAX_WEIGHT
+				}
+				c.weight[i] = weight
+			}
+		}
+	}
+
+	for i := 0; i < c.numPath; i++ {
+		c.remainingBytes[i] = c.weight[i] * c.payloadSize
+	}
+
+	if c.numPath > 0 && !c.pathAvailable[c.currentPath] {
+		if next := c.nextAvailablePath(c.currentPath); next >= 0 {
+			c.currentPath = next
+		}
+	}
+
+	c.logger.Log("WrrScheduler.rebalance(): Weight=%v, Available=%v", c.weight, c.pathAvailable)
+}
+
+func (c *WrrScheduler) UpdatePathCondition(pathID int, rtt time.Duration, ackedBytes uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.updateRTT(pathID, rtt) {
+		return
+	}
+
+	c.ackedBytes[pathID] += ackedBytes
+	if c.inflightBytes[pathID] > ackedBytes {
+		c.inflightBytes[pathID] -= ackedBytes
+	} else {
+		c.inflightBytes[pathID] = 0
+	}
+
+	if c.schedulerType == SCHED_NET_WRR && time.Since(c.lastUpdate) >= NET_WRR_UPDATE_INTERVAL {
+		c.updateNetWeight()
+	}
+}
+
+// Re-balance weights according to delivery rate and RTT of each path
+func (c *WrrScheduler) updateNetWeight() {
+	now := time.Now()
+	elapsed := now.Sub(c.lastUpdate).Seconds()
+	c.lastUpdate = now
+
+	score := make([]float64, c.numPath)
+	maxScore := 0.0
+
+	for i := 0; i < c.numPath; i++ {
+		// Delivery rate in the last interval
+		rate := float64(c.ackedBytes[i]) / elapsed
+		c.ackedBytes[i] = 0
+		if c.deliveryRate[i] == 0 {
+			c.deliveryRate[i] = rate
+		} else {
+			c.deliveryRate[i] = (1-NET_WRR_RATE_ALPHA)*c.deliveryRate[i] + NET_WRR_RATE_ALPHA*rate
+		}
+
+		if !c.pathAvailable[i] || c.deliveryRate[i] == 0 {
+			continue
+		}
+
+		// If in-flight bytes are less than BDP, the path is not fully utilized
+		// and its delivery rate underestimates the capacity -> probe with more traffic
+		score[i] = c.deliveryRate[i]
+		bdp := c.deliveryRate[i] * c.smoothedRTT[i].Seconds()
+		if float64(c.inflightBytes[i]) < bdp {
+			score[i] *= 2
+		}
+
+		if score[i] > maxScore {
+			maxScore = score[i]
+		}
+	}
+
+	for i := 0; i < c.numPath; i++ {
+		// Path which is not measured yet keeps its weight
+		if score[i] == 0 {
+			continue
+		}
+
+		weight := uint32(math.Round(NET_WRR_MAX_WEIGHT * score[i] / maxScore))
+		if weight < 1 {
+			weight = 1
+		}
+		c.weight[i] = weight
+	}
+
+	c.logger.Log("WrrScheduler.updateNetWeight(): Weight=%v, SRTT=%v, DeliveryRate=%v", c.weight, c.smoothedRTT, c.deliveryRate)
+}
+
+// Weighted Round robin
+func (c *WrrScheduler) Scheduling(payloadSize uint32) []int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pathID := 0
+
+	switch c.schedulerType {
+	case SCHED_USER_WRR:
+		pathID = c.scheduling_user_wrr(payloadSize)
+
+	case SCHED_NET_WRR:
+		pathID = c.scheduling_net_wrr(payloadSize)
+
+	default:
+		pathID = c.scheduling_user_wrr(payloadSize)
+	}
+
+	if pathID < 0 {
+		return nil
+	}
+
+	return []int{pathID}
+}
+
+// User-defined weight round robin
+func (c *WrrScheduler) scheduling_user_wrr(payloadSize uint32) int {
+	// skip the current path if it has failed
+	if c.numPath == 0 || !c.pathAvailable[c.currentPath] {
+		c.currentPath = c.nextAvailablePath(c.currentPath)
+		if c.currentPath < 0 {
+			c.currentPath = 0
+			return -1
+		}
+	}
+
+	selectedPath := c.currentPath
+
+	// update remaing bytes and sent bytes of selected path
+	if c.remainingBytes[selectedPath] > payloadSize {
+		c.remainingBytes[selectedPath] -= payloadSize
+	} else {
+		c.remainingBytes[selectedPath] = 0
+	}
+
+	// reset remaining bytes of selected path and change the current path to next path
+	if c.remainingBytes[selectedPath] <= c.payloadSize/8 {
+		c.remainingBytes[selectedPath] = c.weight[selectedPath] * c.payloadSize
+		if next := c.nextAvailablePath(c.currentPath); next >= 0 {
+			c.currentPath = next
+		}
+	}
+
+	return selectedPath
+}
+
+// Network condition based weight round robin
+// Weights are re-balanced by UpdatePathCondition() and applied from the next round
+func (c *WrrScheduler) scheduling_net_wrr(payloadSize uint32) int {
+	selectedPath := c.scheduling_user_wrr(payloadSize)
+	if selectedPath < 0 {
+		return selectedPath
+	}
+
+	c.inflightBytes[selectedPath] += payloadSize
+
+	return selectedPath
+}
@@ -0,0 +1,55 @@
+package multipath
+
+import (
+	"testing"
+)
+
+// Scheduler may be set after additional paths have joined the session (e.g. Accept() after auto-connect)
+func TestWrrSchedulerSetNumPathOnFreshScheduler(t *testing.T) {
+	for _, schedType := range []int{SCHED_USER_WRR, SCHED_NET_WRR} {
+		for numPath := 2; numPath <= 4; numPath++ {
+			scheduler := CreateWrrScheduler(schedType, []uint32{2, 1}, DATA_PACKET_PAYLOAD_SIZE)
+			scheduler.SetNumPath(numPath)
+
+			if len(scheduler.remainingBytes) != numPath || len(scheduler.deliveryRate) != numPath ||
+				len(scheduler.ackedBytes) != numPath || len(scheduler.inflightBytes) != numPath {
+				t.Fatalf("type %d, %d paths: per-path state has %d entries", schedType, numPath, len(scheduler.remainingBytes))
+			}
+
+			weights := scheduler.GetWeights()
+			if len(weights) != numPath {
+				t.Fatalf("type %d, %d paths: %d weights", schedType, numPath, len(weights))
+			}
+
+			// Every path is scheduled in turn
+			used := make([]bool, numPath)
+			for i := 0; i < 100; i++ {
+				pathIDs := scheduler.Scheduling(DATA_PACKET_PAYLOAD_SIZE)
+				if len(pathIDs) != 1 || pathIDs[0] < 0 || pathIDs[0] >= numPath {
+					t.Fatalf("type %d, %d paths: scheduled %v", schedType, numPath, pathIDs)
+				}
+				used[pathIDs[0]] = true
+			}
+			for pathID, ok := range used {
+				if !ok {
+					t.Errorf("type %d, %d paths: path %d is never scheduled", schedType, numPath, pathID)
+				}
+			}
+		}
+	}
+}
+
+// Paths are added one by one as they join
+func TestWrrSchedulerSetNumPathIncrementally(t *testing.T) {
+	scheduler := CreateWrrScheduler(SCHED_USER_WRR, []uint32{2, 1}, DATA_PACKET_PAYLOAD_SIZE)
+	for numPath := 1; numPath <= 3; numPath++ {
+		scheduler.SetNumPath(numPath)
+		if scheduler.currentPath != numPath-1 {
+			t.Fatalf("%d paths: current path %d is not the new path", numPath, scheduler.currentPath)
+		}
+	}
+
+	if len(scheduler.remainingBytes) != 3 {
+		t.Fatalf("remaining bytes of %d paths", len(scheduler.remainingBytes))
+	}
+}